@@ -0,0 +1,130 @@
+package main
+
+import "strings"
+
+type AtomFeed struct {
+	Lang     string       `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Title    string       `xml:"title"`
+	Subtitle string       `xml:"subtitle"`
+	Links    []AtomLink   `xml:"link"`
+	Authors  []AtomPerson `xml:"author"`
+	Entries  []AtomEntry  `xml:"entry"`
+}
+
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+type AtomEntry struct {
+	ID        string       `xml:"id"`
+	Title     AtomText     `xml:"title"`
+	Authors   []AtomPerson `xml:"author"`
+	Links     []AtomLink   `xml:"link"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+	Summary   AtomText     `xml:"summary"`
+	// media:content elements must not be matched by Content
+	MediaContents []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups   []MediaGroup   `xml:"http://search.yahoo.com/mrss/ group"`
+	Content       AtomText       `xml:"content"`
+}
+
+// String returns the text of an Atom text construct, keeping the markup of xhtml content
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// atomAlternateLink returns the href of the first alternate link
+func atomAlternateLink(links []AtomLink) string {
+	for _, link := range links {
+		// a link without rel is an alternate link per RFC 4287
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// atomAuthorNames joins the names of the given Atom authors
+func atomAuthorNames(authors []AtomPerson) string {
+	names := []string{}
+	for _, author := range authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// atomEnclosures returns the enclosure links of an entry
+func atomEnclosures(links []AtomLink) []RSSEnclosure {
+	enclosures := []RSSEnclosure{}
+	for _, link := range links {
+		if link.Rel == "enclosure" {
+			enclosures = append(enclosures, RSSEnclosure{URL: link.Href, Type: link.Type, Length: link.Length})
+		}
+	}
+	return enclosures
+}
+
+// toRSSFeed maps an Atom feed onto the RSSFeed shape used by scrapeFeeds
+func (a *AtomFeed) toRSSFeed() *RSSFeed {
+	feed := &RSSFeed{}
+	feed.Channel.Title = a.Title
+	feed.Channel.Link = atomAlternateLink(a.Links)
+	feed.Channel.Description = a.Subtitle
+	feed.Channel.Language = a.Lang
+
+	feedAuthor := atomAuthorNames(a.Authors)
+
+	for _, entry := range a.Entries {
+		pubDate := entry.Published
+		if pubDate == "" {
+			pubDate = entry.Updated
+		}
+
+		content := entry.Content.String()
+		description := entry.Summary.String()
+		if description == "" {
+			description = content
+		}
+
+		// entries inherit the feed author when they have none
+		author := atomAuthorNames(entry.Authors)
+		if author == "" {
+			author = feedAuthor
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       entry.Title.String(),
+			Link:        atomAlternateLink(entry.Links),
+			Description: description,
+			PubDate:     pubDate,
+			Author:      author,
+			GUID:        entry.ID,
+			Content:     content,
+			Enclosures:  atomEnclosures(entry.Links),
+
+			MediaContents: entry.MediaContents,
+			MediaGroups:   entry.MediaGroups,
+		})
+	}
+
+	return feed
+}
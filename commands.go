@@ -0,0 +1,490 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// exit codes of gator
+const (
+	exitFailure = 1 // the command failed
+	exitUsage   = 2 // the command line was invalid
+)
+
+type command struct {
+	name  string
+	args  []string
+	flags map[string]any
+}
+
+// commandFlag is an option of a command. The type of the default value (bool, int or string) is the
+// type of the flag
+type commandFlag struct {
+	name     string
+	usage    string
+	defValue any
+	// arg names the value of the flag in the usage, e.g. "url"
+	arg string
+	// complete is the kind of values completed for the flag, see completeFeeds
+	complete string
+}
+
+// commandSpec describes a command: how it is called, what it does and the handler running it
+type commandSpec struct {
+	name    string
+	summary string
+	// usage lists the positional arguments, e.g. "<feed_url>" or "[limit]"
+	usage   string
+	minArgs int
+	// maxArgs is the maximum number of positional arguments, -1 for no limit
+	maxArgs int
+	flags   []commandFlag
+	handler func(*state, command) error
+	// complete is the kind of values completed for the positional arguments, see completeFeeds
+	complete string
+	// hidden commands are left out of the command list and the completion scripts
+	hidden bool
+	// noConfig commands run without reading the config or connecting to the database
+	noConfig bool
+	// outputs are the --output formats the command supports besides text
+	outputs []string
+}
+
+type commands struct {
+	specs map[string]commandSpec
+	// names in registration order, as listed by help
+	names []string
+}
+
+// usageError is an invalid command line. It is reported with the usage of the command
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// usageErrorf returns a usageError with a formatted message
+func usageErrorf(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (c *commands) register(spec commandSpec) {
+	if c.specs == nil {
+		c.specs = map[string]commandSpec{}
+	}
+	c.specs[spec.name] = spec
+	c.names = append(c.names, spec.name)
+}
+
+// run parses the flags of cmd, validates its arguments and runs its handler. --help prints the usage
+// of the command instead
+func (c *commands) run(s *state, cmd command) error {
+	spec, ok := c.specs[cmd.name]
+	if !ok {
+		return usageErrorf("unknown command: %s, run 'gator help' for a list of commands", cmd.name)
+	}
+
+	args, flags, err := spec.parse(cmd.args)
+	if errors.Is(err, flag.ErrHelp) {
+		spec.printUsage(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) < spec.minArgs:
+		return usageErrorf("%s: not enough arguments", spec.name)
+	case spec.maxArgs >= 0 && len(args) > spec.maxArgs:
+		return usageErrorf("%s: too many arguments", spec.name)
+	}
+
+	if s.output != outputText && !slices.Contains(spec.outputs, s.output) {
+		return usageErrorf("%s doesn't support --output %s", spec.name, s.output)
+	}
+
+	cmd.args = args
+	cmd.flags = flags
+	return spec.handler(s, cmd)
+}
+
+// parse separates the flags of a command line from its positional arguments. Flags may appear before,
+// between or after the arguments, "--" ends the flags
+func (spec commandSpec) parse(arguments []string) ([]string, map[string]any, error) {
+	fs := flag.NewFlagSet(spec.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	flags := map[string]any{}
+	for _, f := range spec.flags {
+		switch value := f.defValue.(type) {
+		case bool:
+			flags[f.name] = fs.Bool(f.name, value, f.usage)
+		case int:
+			flags[f.name] = fs.Int(f.name, value, f.usage)
+		case string:
+			flags[f.name] = fs.String(f.name, value, f.usage)
+		default:
+			panic(fmt.Sprintf("unsupported type of flag %s: %T", f.name, value))
+		}
+	}
+
+	args := []string{}
+	for {
+		if err := fs.Parse(arguments); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, nil, err
+			}
+			return nil, nil, usageErrorf("%s: %v", spec.name, err)
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return args, flags, nil
+		}
+		// the flag package stops at "--" and at the first argument that isn't a flag
+		if parsed := arguments[:len(arguments)-len(rest)]; len(parsed) > 0 && parsed[len(parsed)-1] == "--" {
+			return append(args, rest...), flags, nil
+		}
+		args = append(args, rest[0])
+		arguments = rest[1:]
+	}
+}
+
+// printUsage writes the usage of a command and its flags to w
+func (spec commandSpec) printUsage(w io.Writer) {
+	usage := "gator " + spec.name
+	if spec.usage != "" {
+		usage += " " + spec.usage
+	}
+	if len(spec.flags) > 0 {
+		usage += " [flags]"
+	}
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", usage, spec.summary)
+
+	if len(spec.flags) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	for _, f := range spec.flags {
+		name := "--" + f.name
+		switch value := f.defValue.(type) {
+		case int:
+			name += " <" + cmp.Or(f.arg, "n") + ">"
+			if value != 0 {
+				f.usage += fmt.Sprintf(" (default: %d)", value)
+			}
+		case string:
+			name += " <" + cmp.Or(f.arg, "value") + ">"
+			if value != "" {
+				f.usage += fmt.Sprintf(" (default: %s)", value)
+			}
+		}
+		fmt.Fprintf(w, "  %-18s %s\n", name, f.usage)
+	}
+}
+
+// printCommands writes the list of commands with their summaries to w
+func (c *commands) printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gator [--output text|json|table] <command> [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	width := len(slices.MaxFunc(c.names, func(a, b string) int { return len(a) - len(b) }))
+	for _, name := range c.names {
+		if c.specs[name].hidden {
+			continue
+		}
+		fmt.Fprintf(w, "  %-*s  %s\n", width, name, c.specs[name].summary)
+	}
+	fmt.Fprintln(w, "\nRun 'gator help <command>' or 'gator <command> --help' for the usage of a command.")
+}
+
+// handlerHelp lists the commands, or prints the usage of one command
+func (c *commands) handlerHelp(s *state, cmd command) error {
+	if len(cmd.args) == 0 {
+		c.printCommands(os.Stdout)
+		return nil
+	}
+
+	spec, ok := c.specs[cmd.args[0]]
+	if !ok {
+		return usageErrorf("unknown command: %s, run 'gator help' for a list of commands", cmd.args[0])
+	}
+	spec.printUsage(os.Stdout)
+	return nil
+}
+
+// boolFlag returns the value of a bool flag of the command
+func (cmd command) boolFlag(name string) bool {
+	return *cmd.flags[name].(*bool)
+}
+
+// intFlag returns the value of an int flag of the command
+func (cmd command) intFlag(name string) int {
+	return *cmd.flags[name].(*int)
+}
+
+// stringFlag returns the value of a string flag of the command
+func (cmd command) stringFlag(name string) string {
+	return strings.TrimSpace(*cmd.flags[name].(*string))
+}
+
+// newCommands registers the commands of gator
+func newCommands() *commands {
+	c := &commands{}
+
+	c.register(commandSpec{
+		name:     "help",
+		summary:  "List the commands, or show the usage of a command",
+		usage:    "[command]",
+		maxArgs:  1,
+		handler:  c.handlerHelp,
+		complete: completeCommands,
+		noConfig: true,
+	})
+	c.register(commandSpec{
+		name:    "register",
+		summary: "Register a user and log in as that user",
+		usage:   "<username>",
+		minArgs: 1,
+		maxArgs: 1,
+		handler: handlerRegister,
+	})
+	c.register(commandSpec{
+		name:     "login",
+		summary:  "Log in as an existing user",
+		usage:    "<username>",
+		minArgs:  1,
+		maxArgs:  1,
+		handler:  handlerLogin,
+		complete: completeUsers,
+	})
+	c.register(commandSpec{
+		name:    "users",
+		summary: "List the registered users",
+		handler: handlerUsers,
+		outputs: []string{outputJSON, outputTable},
+	})
+	c.register(commandSpec{
+		name:    "reset",
+		summary: "Delete all users, feeds and posts",
+		handler: handlerReset,
+	})
+	c.register(commandSpec{
+		name:    "addfeed",
+		summary: "Add a feed, or the feed of a site, and follow it",
+		usage:   "[feed_name] <feed_url>",
+		minArgs: 1,
+		maxArgs: 2,
+		handler: middlewareLoggedIn(handlerAddFeed),
+	})
+	c.register(commandSpec{
+		name:    "feeds",
+		summary: "List all feeds",
+		handler: handlerFeeds,
+		outputs: []string{outputJSON, outputTable},
+	})
+	c.register(commandSpec{
+		name:     "follow",
+		summary:  "Follow an existing feed",
+		usage:    "<feed_url>",
+		minArgs:  1,
+		maxArgs:  1,
+		handler:  middlewareLoggedIn(handlerFollow),
+		complete: completeFeeds,
+	})
+	c.register(commandSpec{
+		name:    "following",
+		summary: "List the feeds you follow with their unread posts",
+		handler: middlewareLoggedIn(handlerFollowing),
+		outputs: []string{outputJSON, outputTable},
+	})
+	c.register(commandSpec{
+		name:     "unfollow",
+		summary:  "Unfollow a feed",
+		usage:    "<feed_url>",
+		minArgs:  1,
+		maxArgs:  1,
+		handler:  middlewareLoggedIn(handlerUnfollow),
+		complete: completeFeeds,
+	})
+	c.register(commandSpec{
+		name:     "import-opml",
+		summary:  "Follow the feeds of an OPML file",
+		usage:    "<file>",
+		minArgs:  1,
+		maxArgs:  1,
+		handler:  middlewareLoggedIn(handlerImportOPML),
+		complete: completeFiles,
+	})
+	c.register(commandSpec{
+		name:     "export-opml",
+		summary:  "Write the feeds you follow as OPML, to stdout without a file",
+		usage:    "[file]",
+		maxArgs:  1,
+		handler:  middlewareLoggedIn(handlerExportOPML),
+		complete: completeFiles,
+	})
+	c.register(commandSpec{
+		name:    "agg",
+		summary: "Fetch due feeds continuously",
+		usage:   "[interval] [concurrency]",
+		maxArgs: 2,
+		flags: []commandFlag{
+			{name: "download", usage: "also download the media of your feeds", defValue: false},
+		},
+		handler: handlerAgg,
+	})
+	c.register(commandSpec{
+		name:    "scrape",
+		summary: "Fetch the least recently fetched feed once",
+		handler: handlerScrape,
+	})
+	c.register(commandSpec{
+		name:    "feed-status",
+		summary: "Show the fetch health of all feeds",
+		usage:   "[limit]",
+		maxArgs: 1,
+		handler: handlerFeedStatus,
+		outputs: []string{outputJSON, outputTable},
+	})
+	c.register(commandSpec{
+		name:     "feed-enable",
+		summary:  "Re-enable a feed disabled after repeated failures",
+		usage:    "<feed_url>",
+		minArgs:  1,
+		maxArgs:  1,
+		handler:  handlerFeedEnable,
+		complete: completeFeeds,
+	})
+	c.register(commandSpec{
+		name:    "browse",
+		summary: "Show the posts of the feeds you follow",
+		usage:   "[limit]",
+		maxArgs: 1,
+		flags: []commandFlag{
+			{name: "unread", usage: "only show unread posts", defValue: false},
+			{name: "offset", usage: "number of posts to skip", defValue: 0},
+			{name: "feed", usage: "only show the posts of the feed with this URL or name", defValue: "", arg: "url|name", complete: completeFeeds},
+			{name: "since", usage: "only show posts from this time on: a date, a RFC 3339 timestamp or an age like 7d", defValue: "", arg: "time"},
+			{name: "until", usage: "only show posts before this time", defValue: "", arg: "time"},
+			{name: "order", usage: "order by published or ingested time", defValue: "published", arg: "order"},
+		},
+		handler: middlewareLoggedIn(handlerBrowse),
+		outputs: []string{outputJSON, outputTable},
+	})
+	c.register(commandSpec{
+		name:    "search",
+		summary: "Search the posts of the feeds you follow",
+		usage:   "<query>",
+		minArgs: 1,
+		maxArgs: -1,
+		flags: []commandFlag{
+			{name: "feed", usage: "only search the posts of the feed with this URL", defValue: "", arg: "url", complete: completeFeeds},
+			{name: "since", usage: "only search posts from this time on: a date, a RFC 3339 timestamp or an age like 7d", defValue: "", arg: "time"},
+			{name: "limit", usage: "number of results", defValue: 10},
+		},
+		handler: middlewareLoggedIn(handlerSearch),
+		outputs: []string{outputJSON, outputTable},
+	})
+	c.register(commandSpec{
+		name:    "read",
+		summary: "Show the full content of a post",
+		usage:   "<post_id>",
+		minArgs: 1,
+		maxArgs: 1,
+		handler: handlerRead,
+	})
+	c.register(commandSpec{
+		name:    "mark-read",
+		summary: "Mark a post, the posts of a feed or all posts as read",
+		usage:   "[post_id]",
+		maxArgs: 1,
+		flags: []commandFlag{
+			{name: "all", usage: "mark all posts of the feeds you follow", defValue: false},
+			{name: "feed", usage: "mark all posts of the feed with this URL", defValue: "", arg: "url", complete: completeFeeds},
+		},
+		handler: middlewareLoggedIn(handlerMarkRead),
+	})
+	c.register(commandSpec{
+		name:    "mark-unread",
+		summary: "Mark a post, the posts of a feed or all posts as unread",
+		usage:   "[post_id]",
+		maxArgs: 1,
+		flags: []commandFlag{
+			{name: "all", usage: "mark all posts", defValue: false},
+			{name: "feed", usage: "mark all posts of the feed with this URL", defValue: "", arg: "url", complete: completeFeeds},
+		},
+		handler: middlewareLoggedIn(handlerMarkUnread),
+	})
+	c.register(commandSpec{
+		name:    "star",
+		summary: "Save a post for later",
+		usage:   "<post_id>",
+		minArgs: 1,
+		maxArgs: 1,
+		handler: middlewareLoggedIn(handlerStar),
+	})
+	c.register(commandSpec{
+		name:    "unstar",
+		summary: "Remove a post from the saved posts",
+		usage:   "<post_id>",
+		minArgs: 1,
+		maxArgs: 1,
+		handler: middlewareLoggedIn(handlerUnstar),
+	})
+	c.register(commandSpec{
+		name:    "starred",
+		summary: "List the posts you saved",
+		handler: middlewareLoggedIn(handlerStarred),
+		outputs: []string{outputJSON, outputTable},
+	})
+	c.register(commandSpec{
+		name:    "enclosures",
+		summary: "List the recent media of the feeds you follow",
+		usage:   "[limit]",
+		maxArgs: 1,
+		handler: middlewareLoggedIn(handlerEnclosures),
+		outputs: []string{outputJSON, outputTable},
+	})
+	c.register(commandSpec{
+		name:    "download",
+		summary: "Download the pending media of the feeds you follow",
+		usage:   "[limit]",
+		maxArgs: 1,
+		handler: middlewareLoggedIn(handlerDownload),
+	})
+	c.register(commandSpec{
+		name:    "tui",
+		summary: "Read your feeds in a full-screen terminal interface",
+		handler: middlewareLoggedIn(handlerTUI),
+	})
+	c.register(commandSpec{
+		name:     "completion",
+		summary:  "Print the shell completion script for bash, zsh or fish",
+		usage:    "<shell>",
+		minArgs:  1,
+		maxArgs:  1,
+		handler:  c.handlerCompletion,
+		complete: completeShells,
+		noConfig: true,
+	})
+	c.register(commandSpec{
+		name:    "__complete",
+		summary: "Print the values completed for a kind of argument",
+		usage:   "<kind>",
+		minArgs: 1,
+		maxArgs: 1,
+		handler: handlerComplete,
+		hidden:  true,
+	})
+
+	return c
+}
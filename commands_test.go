@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestCommandSpecParse(t *testing.T) {
+	spec := commandSpec{
+		name: "browse",
+		flags: []commandFlag{
+			{name: "limit", defValue: 2, usage: "number of posts"},
+			{name: "unread", defValue: false, usage: "only unread posts"},
+			{name: "feed", defValue: "", usage: "feed URL"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		arguments []string
+		args      []string
+		limit     int
+		unread    bool
+		feed      string
+		wantErr   error
+		usageErr  bool
+	}{
+		{
+			name:  "defaults",
+			args:  []string{},
+			limit: 2,
+		},
+		{
+			name:      "flags before arguments",
+			arguments: []string{"--limit", "5", "--unread", "a", "b"},
+			args:      []string{"a", "b"},
+			limit:     5,
+			unread:    true,
+		},
+		{
+			name:      "flags between and after arguments",
+			arguments: []string{"a", "-feed=https://example.com/feed", "b", "--limit=7"},
+			args:      []string{"a", "b"},
+			limit:     7,
+			feed:      "https://example.com/feed",
+		},
+		{
+			name:      "double dash ends the flags",
+			arguments: []string{"--unread", "--", "--limit", "a"},
+			args:      []string{"--limit", "a"},
+			limit:     2,
+			unread:    true,
+		},
+		{
+			name:      "help",
+			arguments: []string{"a", "--help"},
+			wantErr:   flag.ErrHelp,
+		},
+		{
+			name:      "unknown flag",
+			arguments: []string{"--bogus"},
+			usageErr:  true,
+		},
+		{
+			name:      "invalid value",
+			arguments: []string{"--limit", "many"},
+			usageErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, flags, err := spec.parse(tt.arguments)
+
+			var usageErr *usageError
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("parse error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.usageErr:
+				if !errors.As(err, &usageErr) {
+					t.Fatalf("parse error = %v, want a usage error", err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("parse: %v", err)
+			}
+
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %q, want %q", args, tt.args)
+			}
+			if limit := *flags["limit"].(*int); limit != tt.limit {
+				t.Errorf("limit = %d, want %d", limit, tt.limit)
+			}
+			if unread := *flags["unread"].(*bool); unread != tt.unread {
+				t.Errorf("unread = %t, want %t", unread, tt.unread)
+			}
+			if feed := *flags["feed"].(*string); feed != tt.feed {
+				t.Errorf("feed = %q, want %q", feed, tt.feed)
+			}
+		})
+	}
+}
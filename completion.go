@@ -0,0 +1,329 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// kinds of values completed for arguments and flags. Feeds and users are looked up by the hidden
+// __complete command when completing
+const (
+	completeFeeds    = "feeds"
+	completeUsers    = "users"
+	completeFiles    = "files"
+	completeCommands = "commands"
+	completeShells   = "shells"
+)
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// handlerCompletion prints a completion script for a shell, generated from the registered commands
+func (c *commands) handlerCompletion(s *state, cmd command) error {
+	var script string
+	switch cmd.args[0] {
+	case "bash":
+		script = c.bashCompletion()
+	case "zsh":
+		script = c.zshCompletion()
+	case "fish":
+		script = c.fishCompletion()
+	default:
+		return usageErrorf("unsupported shell: %s (use bash, zsh or fish)", cmd.args[0])
+	}
+
+	_, err := os.Stdout.WriteString(script)
+	return err
+}
+
+// handlerComplete prints the feed URLs or the user names, one per line, for the completion scripts
+func handlerComplete(s *state, cmd command) error {
+	switch cmd.args[0] {
+	case completeFeeds:
+		feeds, err := s.db.GetFeeds(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, feed := range feeds {
+			fmt.Println(feed.Url.String)
+		}
+	case completeUsers:
+		users, err := s.db.GetUsers(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			fmt.Println(user)
+		}
+	default:
+		return usageErrorf("unknown completion kind: %s", cmd.args[0])
+	}
+	return nil
+}
+
+// visibleCommands returns the specs of the commands that aren't hidden, in registration order
+func (c *commands) visibleCommands() []commandSpec {
+	specs := []commandSpec{}
+	for _, name := range c.names {
+		if !c.specs[name].hidden {
+			specs = append(specs, c.specs[name])
+		}
+	}
+	return specs
+}
+
+// commandNames returns the names of the commands that aren't hidden
+func (c *commands) commandNames() []string {
+	names := []string{}
+	for _, spec := range c.visibleCommands() {
+		names = append(names, spec.name)
+	}
+	return names
+}
+
+// takesValue reports whether a flag is followed by a value
+func (f commandFlag) takesValue() bool {
+	_, isBool := f.defValue.(bool)
+	return !isBool
+}
+
+// bashCompletion returns the bash completion script
+func (c *commands) bashCompletion() string {
+	b := strings.Builder{}
+	b.WriteString(`# bash completion for gator, load it with: source <(gator completion bash)
+
+_gator_values() {
+    case "$1" in
+        feeds|users) gator __complete "$1" 2>/dev/null ;;
+`)
+	fmt.Fprintf(&b, "        %s) echo %q ;;\n", completeCommands, strings.Join(c.commandNames(), " "))
+	fmt.Fprintf(&b, "        %s) echo %q ;;\n", completeShells, strings.Join(completionShells, " "))
+	b.WriteString(`    esac
+}
+
+_gator_complete() {
+    if [[ "$1" == files ]]; then
+        compopt -o default 2>/dev/null
+        COMPREPLY=()
+        return
+    fi
+    COMPREPLY=($(compgen -W "$(_gator_values "$1")" -- "$cur"))
+    # feed URLs contain colons, which bash treats as word breaks
+    if declare -F __ltrim_colon_completions >/dev/null; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+
+_gator() {
+    local cur prev words cword
+    if declare -F _init_completion >/dev/null; then
+        _init_completion -n : || return
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        prev="${COMP_WORDS[COMP_CWORD-1]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+
+    if [[ "$prev" == --output ]]; then
+        COMPREPLY=($(compgen -W "text json table" -- "$cur"))
+        return
+    fi
+
+    local cmd="" i
+    for ((i = 1; i < cword; i++)); do
+        case "${words[i]}" in
+            --output) ((i++)) ;;
+            -*) ;;
+            *) cmd="${words[i]}"; break ;;
+        esac
+    done
+
+    if [[ -z "$cmd" ]]; then
+        COMPREPLY=($(compgen -W "--output $(_gator_values commands)" -- "$cur"))
+        return
+    fi
+
+    case "$cmd" in
+`)
+	for _, spec := range c.visibleCommands() {
+		fmt.Fprintf(&b, "        %s)\n", spec.name)
+
+		flagNames := []string{"--help"}
+		for _, f := range spec.flags {
+			flagNames = append(flagNames, "--"+f.name)
+			if f.takesValue() {
+				fmt.Fprintf(&b, "            if [[ \"$prev\" == --%s ]]; then _gator_complete %s; return; fi\n", f.name, cmp.Or(f.complete, "none"))
+			}
+		}
+		fmt.Fprintf(&b, "            if [[ \"$cur\" == -* ]]; then COMPREPLY=($(compgen -W %q -- \"$cur\")); return; fi\n", strings.Join(flagNames, " "))
+		if spec.complete != "" {
+			fmt.Fprintf(&b, "            _gator_complete %s\n", spec.complete)
+		}
+		b.WriteString("            ;;\n")
+	}
+	b.WriteString(`    esac
+}
+
+complete -F _gator gator
+`)
+	return b.String()
+}
+
+// zshCompletion returns the zsh completion script
+func (c *commands) zshCompletion() string {
+	b := strings.Builder{}
+	b.WriteString(`#compdef gator
+# zsh completion for gator, load it with: source <(gator completion zsh)
+
+_gator_values() {
+    case "$1" in
+        feeds|users) compadd -- ${(f)"$(gator __complete "$1" 2>/dev/null)"} ;;
+        files) _files ;;
+`)
+	fmt.Fprintf(&b, "        %s) compadd -- %s ;;\n", completeCommands, strings.Join(c.commandNames(), " "))
+	fmt.Fprintf(&b, "        %s) compadd -- %s ;;\n", completeShells, strings.Join(completionShells, " "))
+	b.WriteString(`    esac
+}
+
+_gator() {
+    local curcontext="$curcontext" state line
+    local -a commands
+    commands=(
+`)
+	for _, spec := range c.visibleCommands() {
+		fmt.Fprintf(&b, "        %s\n", zshQuote(spec.name+":"+strings.ReplaceAll(spec.summary, ":", `\:`)))
+	}
+	b.WriteString(`    )
+
+    _arguments -C \
+        '--output[output format]:format:(text json table)' \
+        '1:command:->command' \
+        '*::argument:->argument'
+
+    case $state in
+        command)
+            _describe -t commands 'gator command' commands
+            ;;
+        argument)
+            case $line[1] in
+`)
+	for _, spec := range c.visibleCommands() {
+		specs := []string{zshQuote("--help[show the usage of the command]")}
+		for _, f := range spec.flags {
+			option := "--" + f.name + "[" + zshEscapeDescription(f.usage) + "]"
+			if f.takesValue() {
+				option += ":" + cmp.Or(f.arg, "value") + ":"
+				if f.complete != "" {
+					option += "{_gator_values " + f.complete + "}"
+				}
+			}
+			specs = append(specs, zshQuote(option))
+		}
+		argument := "*:argument:"
+		if spec.complete != "" {
+			argument += "{_gator_values " + spec.complete + "}"
+		}
+		specs = append(specs, zshQuote(argument))
+		fmt.Fprintf(&b, "                %s) _arguments %s ;;\n", spec.name, strings.Join(specs, " "))
+	}
+	b.WriteString(`            esac
+            ;;
+    esac
+}
+
+if [[ "$funcstack[1]" == "_gator" ]]; then
+    _gator "$@"
+else
+    compdef _gator gator
+fi
+`)
+	return b.String()
+}
+
+// fishCompletion returns the fish completion script
+func (c *commands) fishCompletion() string {
+	b := strings.Builder{}
+	b.WriteString(`# fish completion for gator, load it with: gator completion fish | source
+
+function __gator_command
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    while set -q tokens[1]
+        switch $tokens[1]
+            case --output
+                set -e tokens[1]
+            case '-*'
+            case '*'
+                echo $tokens[1]
+                return 0
+        end
+        set -e tokens[1]
+    end
+    return 1
+end
+
+function __gator_needs_command
+    not __gator_command >/dev/null
+end
+
+function __gator_using_command
+    test "$(__gator_command)" = $argv[1]
+end
+
+complete -c gator -f
+complete -c gator -n __gator_needs_command -l output -x -a 'text json table' -d 'Output format'
+`)
+	for _, spec := range c.visibleCommands() {
+		fmt.Fprintf(&b, "\ncomplete -c gator -n __gator_needs_command -a %s -d %s\n", spec.name, fishQuote(spec.summary))
+
+		condition := fishQuote("__gator_using_command " + spec.name)
+		for _, f := range spec.flags {
+			line := fmt.Sprintf("complete -c gator -n %s -l %s", condition, f.name)
+			if f.takesValue() {
+				line += " -x"
+				if values := c.fishValues(f.complete); values != "" {
+					line += " -a " + values
+				}
+			}
+			fmt.Fprintf(&b, "%s -d %s\n", line, fishQuote(f.usage))
+		}
+		if spec.complete == completeFiles {
+			fmt.Fprintf(&b, "complete -c gator -n %s -F\n", condition)
+		} else if values := c.fishValues(spec.complete); values != "" {
+			fmt.Fprintf(&b, "complete -c gator -n %s -a %s\n", condition, values)
+		}
+	}
+	return b.String()
+}
+
+// fishValues returns the fish argument list completing a kind of values, or "" if there is none
+func (c *commands) fishValues(kind string) string {
+	switch kind {
+	case completeFeeds, completeUsers:
+		return fishQuote("(gator __complete " + kind + " 2>/dev/null)")
+	case completeCommands:
+		return fishQuote(strings.Join(c.commandNames(), " "))
+	case completeShells:
+		return fishQuote(strings.Join(completionShells, " "))
+	default:
+		return ""
+	}
+}
+
+// fishQuote quotes s for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// zshQuote quotes s for zsh
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshEscapeDescription escapes the brackets and colons of an _arguments description
+func zshEscapeDescription(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// feedLinkTypes are the link types advertising a feed, in order of preference
+var feedLinkTypes = []string{
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/feed+json",
+	"application/json",
+}
+
+// commonFeedPaths are tried when a page doesn't advertise its feeds
+var commonFeedPaths = []string{"/feed", "/rss.xml", "/index.xml", "/atom.xml", "/feed.xml", "/rss"}
+
+// maxDiscoveryPageSize bounds the part of a HTML page searched for feed links
+const maxDiscoveryPageSize = 2 << 20
+
+// feedCandidate is a feed advertised by a HTML page
+type feedCandidate struct {
+	URL   string
+	Type  string
+	Title string
+}
+
+// discoverFeed returns the feed to subscribe to for pageURL with its fetched content. URLs that don't
+// serve HTML must be a feed themselves. For HTML pages the advertised feeds and then common feed paths
+// are tried, and the first one that parses as a feed is returned
+func discoverFeed(ctx context.Context, pageURL string) (string, *RSSFeed, feedResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return "", nil, feedResponse{}, err
+	}
+	req.Header.Set("User-Agent", "gator")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", nil, feedResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	// error pages are HTML too, and searching them would pick an unrelated feed
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil, feedResponse{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDiscoveryPageSize))
+	if err != nil {
+		return "", nil, feedResponse{}, err
+	}
+	if !isHTMLPage(resp.Header.Get("Content-Type"), body) {
+		// only HTML pages are cut short, feeds are read in full
+		rest, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", nil, feedResponse{}, err
+		}
+		meta := newFeedResponse(resp)
+		feed, err := decodeFeed(resp.Header.Get("Content-Type"), append(body, rest...), &meta)
+		if err != nil {
+			return "", nil, meta, fmt.Errorf("not a valid feed: %w", err)
+		}
+		return pageURL, feed, meta, nil
+	}
+
+	// links are resolved against the final URL after redirects
+	base := resp.Request.URL
+
+	candidates := feedLinks(base, body)
+	for _, path := range commonFeedPaths {
+		candidate := base.ResolveReference(&url.URL{Path: path}).String()
+		if !slices.ContainsFunc(candidates, func(c feedCandidate) bool { return c.URL == candidate }) {
+			candidates = append(candidates, feedCandidate{URL: candidate})
+		}
+	}
+
+	for _, candidate := range candidates {
+		feed, meta, err := fetchFeedConditional(ctx, candidate.URL, "", "")
+		if err == nil {
+			fmt.Printf("Discovered feed %s on %s\n", candidate.URL, pageURL)
+			return candidate.URL, feed, meta, nil
+		}
+	}
+
+	return "", nil, feedResponse{}, fmt.Errorf("no feed found on %s", pageURL)
+}
+
+// isHTMLPage reports whether a response is a HTML page rather than a feed. The body wins over the
+// content type, as feeds are sometimes served as text/html
+func isHTMLPage(contentType string, body []byte) bool {
+	if isJSONFeed("", body) {
+		return false
+	}
+	if root, err := feedRootElement(body); err == nil && slices.Contains([]string{"rss", "feed", "RDF"}, root.Local) {
+		return false
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	}
+	start := bytes.ToLower(bytes.TrimSpace(body[:min(len(body), 512)]))
+	return bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.HasPrefix(start, []byte("<html"))
+}
+
+// feedLinks returns the feeds advertised by <link rel="alternate"> tags of a page, best first.
+// Comment feeds are ranked after the feeds of the site's posts
+func feedLinks(base *url.URL, page []byte) []feedCandidate {
+	candidates := []feedCandidate{}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := tokenizer.TagName()
+		if string(name) != "link" {
+			continue
+		}
+
+		attrs := map[string]string{}
+		for hasAttr {
+			var key, value []byte
+			key, value, hasAttr = tokenizer.TagAttr()
+			attrs[string(key)] = string(value)
+		}
+
+		rels := strings.Fields(strings.ToLower(attrs["rel"]))
+		linkType := strings.ToLower(strings.TrimSpace(attrs["type"]))
+		if !slices.Contains(rels, "alternate") || !slices.Contains(feedLinkTypes, linkType) || attrs["href"] == "" {
+			continue
+		}
+
+		href, err := base.Parse(attrs["href"])
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, feedCandidate{URL: href.String(), Type: linkType, Title: attrs["title"]})
+	}
+
+	rank := func(c feedCandidate) int {
+		r := slices.Index(feedLinkTypes, c.Type)
+		if strings.Contains(strings.ToLower(c.Title+" "+c.URL), "comments") {
+			r += len(feedLinkTypes)
+		}
+		return r
+	}
+	slices.SortStableFunc(candidates, func(a, b feedCandidate) int {
+		return rank(a) - rank(b)
+	})
+
+	return candidates
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deoreal/gator/internal/database"
+)
+
+// download states of an enclosure, stored in post_enclosures.download_status
+const (
+	downloadPartial  = "partial"
+	downloadComplete = "complete"
+	downloadFailed   = "failed"
+	downloadPruned   = "pruned"
+)
+
+// maxDownloadAttempts is the number of failed attempts after which an enclosure isn't downloaded anymore.
+// Attempts that add to a partial file don't count
+const maxDownloadAttempts = 5
+
+// downloadTimeout bounds a single download. A download that runs out of time is resumed next time
+const downloadTimeout = 30 * time.Minute
+
+// handlerDownload downloads the pending enclosures of the feeds the user follows
+func handlerDownload(s *state, cmd command, user database.User) error {
+	limit := int32(10) // default limit
+
+	if len(cmd.args) > 0 {
+		parsedLimit, err := strconv.Atoi(cmd.args[0])
+		if err != nil || parsedLimit < 1 {
+			return usageErrorf("invalid limit: %s", cmd.args[0])
+		}
+		limit = int32(parsedLimit)
+	}
+
+	downloaded, err := downloadEnclosures(s, user, limit)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Downloaded %d enclosures\n", downloaded)
+	return nil
+}
+
+// downloadEnclosures downloads up to limit pending enclosures of the user's feeds, newest first, and
+// prunes the oldest downloads once the quota is exceeded. It returns the number of completed downloads
+func downloadEnclosures(s *state, user database.User, limit int32) (int, error) {
+	dir, err := s.conf.DownloadDirectory()
+	if err != nil {
+		return 0, err
+	}
+
+	enclosures, err := s.db.GetEnclosuresToDownload(context.Background(), database.GetEnclosuresToDownloadParams{
+		UserID:           user.ID,
+		DownloadAttempts: maxDownloadAttempts,
+		Limit:            limit,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get enclosures to download: %w", err)
+	}
+
+	downloaded := 0
+	for _, enclosure := range enclosures {
+		// partial downloads are resumed where they were stored
+		target := enclosurePath(dir, enclosure)
+		if enclosure.DownloadPath.Valid {
+			target = enclosure.DownloadPath.String
+		}
+
+		// mark the download as started, so it is resumed if the process is interrupted
+		if err := setDownloadState(s, enclosure.ID, downloadPartial, target, 0); err != nil {
+			return downloaded, err
+		}
+
+		before := int64(0)
+		if info, err := os.Stat(target); err == nil {
+			before = info.Size()
+		}
+
+		fmt.Printf("Downloading %s\n", enclosure.Url)
+		ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+		size, err := downloadFile(ctx, enclosure.Url, target)
+		cancel()
+
+		status := downloadComplete
+		if err != nil {
+			log.Printf("Failed to download %s: %v", enclosure.Url, err)
+			status = downloadFailed
+			if size > 0 {
+				status = downloadPartial
+			}
+			if size <= before {
+				if err := s.db.IncrementEnclosureDownloadAttempts(context.Background(), enclosure.ID); err != nil {
+					return downloaded, err
+				}
+			}
+		}
+		if err := setDownloadState(s, enclosure.ID, status, target, size); err != nil {
+			return downloaded, err
+		}
+		if status == downloadComplete {
+			downloaded++
+		}
+	}
+
+	if err := pruneDownloads(s, s.conf.DownloadQuotaBytes()); err != nil {
+		return downloaded, fmt.Errorf("couldn't prune downloads: %w", err)
+	}
+
+	return downloaded, nil
+}
+
+// setDownloadState records the download state of an enclosure
+func setDownloadState(s *state, id int32, status, target string, size int64) error {
+	return s.db.UpdateEnclosureDownload(context.Background(), database.UpdateEnclosureDownloadParams{
+		ID:              id,
+		DownloadStatus:  sql.NullString{String: status, Valid: true},
+		DownloadPath:    sql.NullString{String: target, Valid: target != ""},
+		DownloadedBytes: size,
+		DownloadedAt:    sql.NullTime{Time: time.Now(), Valid: status == downloadComplete},
+	})
+}
+
+// enclosurePath returns the file an enclosure is downloaded to, in a folder per feed
+func enclosurePath(dir string, enclosure database.GetEnclosuresToDownloadRow) string {
+	folder := sanitizeFileName(enclosure.FeedName.String)
+	if folder == "" {
+		folder = fmt.Sprintf("feed-%d", enclosure.FeedID)
+	}
+
+	name := "enclosure"
+	if u, err := url.Parse(enclosure.Url); err == nil {
+		if base := sanitizeFileName(path.Base(u.Path)); base != "" {
+			name = base
+		}
+	}
+
+	// the enclosure id keeps files with the same name apart
+	return filepath.Join(dir, folder, fmt.Sprintf("%d-%s", enclosure.ID, name))
+}
+
+// sanitizeFileName replaces the characters that aren't safe in file names
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_', r == ' ':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimSpace(name))
+	return strings.Trim(name, ". ")
+}
+
+// downloadFile downloads url to target, resuming an existing partial file with a HTTP Range request.
+// It returns the size of the file on disk
+func downloadFile(ctx context.Context, fileURL, target string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return 0, err
+	}
+
+	offset := int64(0)
+	if info, err := os.Stat(target); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return offset, err
+	}
+	req.Header.Set("User-Agent", "gator")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return offset, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// the server ignored the range, start over
+		flags |= os.O_TRUNC
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the file was already complete
+		if offset > 0 {
+			return offset, nil
+		}
+		return offset, fmt.Errorf("unexpected status: %s", resp.Status)
+	default:
+		return offset, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	file, err := os.OpenFile(target, flags, 0o644)
+	if err != nil {
+		return offset, err
+	}
+
+	written, err := io.Copy(file, resp.Body)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return offset + written, err
+}
+
+// pruneDownloads deletes the oldest downloads until the download directory fits the quota. Downloads of
+// starred posts count towards the quota but are never deleted
+func pruneDownloads(s *state, quota int64) error {
+	if quota <= 0 {
+		return nil
+	}
+
+	downloads, err := s.db.GetCompletedDownloads(context.Background())
+	if err != nil {
+		return err
+	}
+
+	total := int64(0)
+	for _, download := range downloads {
+		total += download.DownloadedBytes
+	}
+
+	for _, download := range downloads {
+		if total <= quota {
+			break
+		}
+		if download.Starred {
+			continue
+		}
+
+		if err := os.Remove(download.DownloadPath.String); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := setDownloadState(s, download.ID, downloadPruned, "", 0); err != nil {
+			return err
+		}
+		fmt.Printf("Pruned %s\n", download.DownloadPath.String)
+		total -= download.DownloadedBytes
+	}
+
+	return nil
+}
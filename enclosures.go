@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/deoreal/gator/internal/database"
+)
+
+type RSSEnclosure struct {
+	URL      string `xml:"url,attr"`
+	Length   string `xml:"length,attr"`
+	Type     string `xml:"type,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+// MediaContent is a Media RSS <media:content> element
+type MediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+// MediaGroup is a Media RSS <media:group> element, holding alternative versions of the same media
+type MediaGroup struct {
+	Contents []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+// itemEnclosures merges the <enclosure> and <media:content> elements of an item, including those nested
+// in <media:group>, applying <itunes:duration> to enclosures that carry no duration of their own
+func itemEnclosures(item RSSItem) []RSSEnclosure {
+	enclosures := []RSSEnclosure{}
+	seen := map[string]bool{}
+
+	add := func(enclosure RSSEnclosure) {
+		enclosure.URL = strings.TrimSpace(enclosure.URL)
+		if enclosure.URL == "" || seen[enclosure.URL] {
+			return
+		}
+		seen[enclosure.URL] = true
+		if enclosure.Duration == "" {
+			enclosure.Duration = item.ITunesDuration
+		}
+		enclosures = append(enclosures, enclosure)
+	}
+
+	for _, enclosure := range item.Enclosures {
+		add(enclosure)
+	}
+	mediaContents := item.MediaContents
+	for _, group := range item.MediaGroups {
+		mediaContents = append(mediaContents, group.Contents...)
+	}
+	for _, media := range mediaContents {
+		add(RSSEnclosure{URL: media.URL, Type: media.Type, Length: media.FileSize, Duration: media.Duration})
+	}
+
+	return enclosures
+}
+
+// parseMediaDuration parses a duration given in seconds or as [[HH:]MM:]SS into seconds
+func parseMediaDuration(duration string) (int32, bool) {
+	duration = strings.TrimSpace(duration)
+	if duration == "" {
+		return 0, false
+	}
+
+	seconds := 0.0
+	for part := range strings.SplitSeq(duration, ":") {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + value
+	}
+	return int32(seconds), true
+}
+
+// formatMediaDuration formats seconds as H:MM:SS or M:SS
+func formatMediaDuration(seconds int32) string {
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// formatEnclosure describes an enclosure on a single line
+func formatEnclosure(enclosure database.PostEnclosure) string {
+	details := []string{}
+	if enclosure.MimeType.Valid {
+		details = append(details, enclosure.MimeType.String)
+	}
+	if enclosure.Length.Valid && enclosure.Length.Int64 > 0 {
+		details = append(details, fmt.Sprintf("%.1f MB", float64(enclosure.Length.Int64)/(1024*1024)))
+	}
+	if enclosure.Duration.Valid {
+		details = append(details, formatMediaDuration(enclosure.Duration.Int32))
+	}
+	if len(details) == 0 {
+		return enclosure.Url
+	}
+	return fmt.Sprintf("%s (%s)", enclosure.Url, strings.Join(details, ", "))
+}
+
+// storeEnclosures replaces the enclosures of a post. Enclosures that are still part of the post are
+// updated in place, so their download state is kept
+func storeEnclosures(s *state, postID int32, enclosures []RSSEnclosure) error {
+	urls := []string{}
+	for _, enclosure := range enclosures {
+		urls = append(urls, enclosure.URL)
+	}
+	err := s.db.DeleteStalePostEnclosures(context.Background(), database.DeleteStalePostEnclosuresParams{
+		PostID: postID,
+		Urls:   urls,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, enclosure := range enclosures {
+		length, lengthErr := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64)
+		duration, hasDuration := parseMediaDuration(enclosure.Duration)
+
+		err := s.db.CreatePostEnclosure(context.Background(), database.CreatePostEnclosureParams{
+			PostID:   postID,
+			Url:      enclosure.URL,
+			MimeType: sql.NullString{String: enclosure.Type, Valid: enclosure.Type != ""},
+			Length:   sql.NullInt64{Int64: length, Valid: lengthErr == nil && length > 0},
+			Duration: sql.NullInt32{Int32: duration, Valid: hasDuration},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handlerEnclosures lists the most recent media attachments of the feeds the user follows
+func handlerEnclosures(s *state, cmd command, user database.User) error {
+	limit := int32(10) // default limit
+
+	if len(cmd.args) > 0 {
+		parsedLimit, err := strconv.Atoi(cmd.args[0])
+		if err != nil || parsedLimit < 1 {
+			return usageErrorf("invalid limit: %s", cmd.args[0])
+		}
+		limit = int32(parsedLimit)
+	}
+
+	enclosures, err := s.db.GetRecentEnclosuresForUser(context.Background(), database.GetRecentEnclosuresForUserParams{
+		UserID: user.ID,
+		Limit:  limit,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't get enclosures for user: %w", err)
+	}
+
+	switch s.output {
+	case outputJSON:
+		items := make([]enclosureJSON, 0, len(enclosures))
+		for _, enclosure := range enclosures {
+			item := enclosureJSON{
+				ID:          enclosure.ID,
+				PostID:      enclosure.PostID,
+				PostTitle:   enclosure.PostTitle.String,
+				PublishedAt: jsonTime(enclosure.PublishedAt),
+				Feed:        enclosure.FeedName.String,
+				URL:         enclosure.Url,
+				MimeType:    jsonString(enclosure.MimeType),
+			}
+			if enclosure.Length.Valid {
+				item.Length = &enclosure.Length.Int64
+			}
+			if enclosure.Duration.Valid {
+				item.DurationSeconds = &enclosure.Duration.Int32
+			}
+			items = append(items, item)
+		}
+		return writeJSON(items)
+	case outputTable:
+		rows := [][]string{}
+		for _, enclosure := range enclosures {
+			duration := "-"
+			if enclosure.Duration.Valid {
+				duration = formatMediaDuration(enclosure.Duration.Int32)
+			}
+			rows = append(rows, []string{
+				enclosure.PostTitle.String,
+				enclosure.FeedName.String,
+				tableTime(enclosure.PublishedAt),
+				duration,
+				enclosure.Url,
+			})
+		}
+		return writeTable([]string{"POST", "FEED", "PUBLISHED", "DURATION", "URL"}, rows)
+	}
+
+	if len(enclosures) == 0 {
+		fmt.Println("No media found for the current user")
+		return nil
+	}
+
+	fmt.Printf("Media for %s:\n", user.Name)
+	for _, enclosure := range enclosures {
+		fmt.Printf("Title: %s\n", enclosure.PostTitle.String)
+		fmt.Printf("Feed: %s\n", enclosure.FeedName.String)
+		if enclosure.PublishedAt.Valid {
+			fmt.Printf("Published: %s\n", enclosure.PublishedAt.Time.Format("2006-01-02 15:04:05"))
+		}
+		fmt.Printf("Media: %s\n", formatEnclosure(database.PostEnclosure{
+			Url:      enclosure.Url,
+			MimeType: enclosure.MimeType,
+			Length:   enclosure.Length,
+			Duration: enclosure.Duration,
+		}))
+		fmt.Println("---")
+	}
+
+	return nil
+}
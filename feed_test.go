@@ -0,0 +1,223 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// parsedItem holds the RSSItem fields stored for a post
+type parsedItem struct {
+	Title, Link, Description, PubDate, Author, GUID, Content string
+}
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		title       string
+		link        string
+		description string
+		language    string
+		items       []parsedItem
+		enclosures  []RSSEnclosure
+	}{
+		{
+			name:        "rss",
+			contentType: "application/rss+xml",
+			body: `<?xml version="1.0"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:media="http://search.yahoo.com/mrss/">
+  <channel>
+    <itunes:title>Show</itunes:title>
+    <title>Blog</title>
+    <link>https://example.com/</link>
+    <description>Posts</description>
+    <language>en</language>
+    <item>
+      <itunes:title>Episode title</itunes:title>
+      <media:description>Media description</media:description>
+      <title>Post</title>
+      <link>https://example.com/post</link>
+      <description>Summary</description>
+      <pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate>
+      <guid>post-1</guid>
+      <enclosure url="https://example.com/a.mp3" type="audio/mpeg" length="42"/>
+    </item>
+    <item>
+      <itunes:title>Episode</itunes:title>
+      <itunes:author>Host</itunes:author>
+      <guid>post-2</guid>
+    </item>
+  </channel>
+</rss>`,
+			title:       "Blog",
+			link:        "https://example.com/",
+			description: "Posts",
+			language:    "en",
+			items: []parsedItem{
+				{Title: "Post", Link: "https://example.com/post", Description: "Summary", PubDate: "Mon, 02 Jan 2006 15:04:05 GMT", GUID: "post-1"},
+				{Title: "Episode", Author: "Host", GUID: "post-2"},
+			},
+			enclosures: []RSSEnclosure{{URL: "https://example.com/a.mp3", Type: "audio/mpeg", Length: "42"}},
+		},
+		{
+			name:        "atom",
+			contentType: "application/atom+xml",
+			body: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="de">
+  <title>Atom blog</title>
+  <subtitle>Entries</subtitle>
+  <link rel="self" href="https://example.com/feed.atom"/>
+  <link href="https://example.com/"/>
+  <author><name>Feed author</name></author>
+  <entry>
+    <id>urn:entry:1</id>
+    <title type="html">First &amp;amp; last</title>
+    <link rel="alternate" href="https://example.com/1"/>
+    <link rel="enclosure" href="https://example.com/1.mp3" type="audio/mpeg" length="7"/>
+    <updated>2006-01-02T15:04:05Z</updated>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Body</p></div></content>
+  </entry>
+  <entry>
+    <id>urn:entry:2</id>
+    <title>Second</title>
+    <author><name>Ann</name></author>
+    <author><name>Bob</name></author>
+    <published>2006-01-01T00:00:00Z</published>
+    <updated>2006-01-03T00:00:00Z</updated>
+    <summary>Short</summary>
+    <content>Long</content>
+  </entry>
+</feed>`,
+			title:       "Atom blog",
+			link:        "https://example.com/",
+			description: "Entries",
+			language:    "de",
+			items: []parsedItem{
+				{
+					Title:       "First &amp; last",
+					Link:        "https://example.com/1",
+					Description: `<div xmlns="http://www.w3.org/1999/xhtml"><p>Body</p></div>`,
+					PubDate:     "2006-01-02T15:04:05Z",
+					Author:      "Feed author",
+					GUID:        "urn:entry:1",
+					Content:     `<div xmlns="http://www.w3.org/1999/xhtml"><p>Body</p></div>`,
+				},
+				{Title: "Second", Description: "Short", PubDate: "2006-01-01T00:00:00Z", Author: "Ann, Bob", GUID: "urn:entry:2", Content: "Long"},
+			},
+			enclosures: []RSSEnclosure{{URL: "https://example.com/1.mp3", Type: "audio/mpeg", Length: "7"}},
+		},
+		{
+			name:        "rdf",
+			contentType: "application/rdf+xml",
+			body: `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="https://example.com/">
+    <title>RDF site</title>
+    <link>https://example.com/</link>
+    <description>Items</description>
+    <dc:language>fr</dc:language>
+  </channel>
+  <item rdf:about="https://example.com/item">
+    <title>Item</title>
+    <link>https://example.com/item</link>
+    <description>Text</description>
+    <dc:date>2006-01-02T15:04:05Z</dc:date>
+    <dc:creator>Claire</dc:creator>
+  </item>
+</rdf:RDF>`,
+			title:       "RDF site",
+			link:        "https://example.com/",
+			description: "Items",
+			language:    "fr",
+			items: []parsedItem{
+				{Title: "Item", Link: "https://example.com/item", Description: "Text", PubDate: "2006-01-02T15:04:05Z", Author: "Claire", GUID: "https://example.com/item"},
+			},
+		},
+		{
+			name:        "json feed",
+			contentType: "text/plain",
+			body: `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON blog",
+  "home_page_url": "https://example.com/",
+  "description": "Notes",
+  "language": "nl",
+  "authors": [{"name": "Feed author"}],
+  "items": [
+    {
+      "id": 1,
+      "external_url": "https://example.org/linked",
+      "title": "Linked",
+      "content_text": "Text",
+      "date_modified": "2006-01-02T15:04:05Z",
+      "attachments": [{"url": "https://example.com/1.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 9, "duration_in_seconds": 61.5}]
+    },
+    {
+      "id": "2",
+      "url": "https://example.com/2",
+      "title": "Second",
+      "content_html": "<p>Html</p>",
+      "summary": "Summary",
+      "date_published": "2006-01-01T00:00:00Z",
+      "author": {"name": "Dora"}
+    }
+  ]
+}`,
+			title:       "JSON blog",
+			link:        "https://example.com/",
+			description: "Notes",
+			language:    "nl",
+			items: []parsedItem{
+				{Title: "Linked", Link: "https://example.org/linked", Description: "Text", PubDate: "2006-01-02T15:04:05Z", Author: "Feed author", GUID: "1", Content: "Text"},
+				{Title: "Second", Link: "https://example.com/2", Description: "<p>Html</p>", PubDate: "2006-01-01T00:00:00Z", Author: "Dora", GUID: "2", Content: "<p>Html</p>"},
+			},
+			enclosures: []RSSEnclosure{{URL: "https://example.com/1.mp3", Type: "audio/mpeg", Length: "9", Duration: "61.5"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed(tt.contentType, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+
+			channel := feed.Channel
+			if channel.Title != tt.title || channel.Link != tt.link || channel.Description != tt.description || channel.Language != tt.language {
+				t.Errorf("channel = %q, %q, %q, %q, want %q, %q, %q, %q",
+					channel.Title, channel.Link, channel.Description, channel.Language,
+					tt.title, tt.link, tt.description, tt.language)
+			}
+
+			items := []parsedItem{}
+			enclosures := []RSSEnclosure{}
+			for _, item := range channel.Item {
+				items = append(items, parsedItem{
+					Title:       item.Title,
+					Link:        item.Link,
+					Description: item.Description,
+					PubDate:     item.PubDate,
+					Author:      item.Author,
+					GUID:        item.GUID,
+					Content:     item.Content,
+				})
+				enclosures = append(enclosures, item.Enclosures...)
+			}
+			if !reflect.DeepEqual(items, tt.items) {
+				t.Errorf("items = %+v, want %+v", items, tt.items)
+			}
+			if len(enclosures) > 0 || len(tt.enclosures) > 0 {
+				if !reflect.DeepEqual(enclosures, tt.enclosures) {
+					t.Errorf("enclosures = %+v, want %+v", enclosures, tt.enclosures)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeedUnsupported(t *testing.T) {
+	if _, err := parseFeed("text/xml", []byte(`<html><body>Not a feed</body></html>`)); err == nil {
+		t.Error("parseFeed accepted a HTML document")
+	}
+}
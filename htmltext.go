@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const textWidth = 80
+
+// blockElements start on a new paragraph when rendered as text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "blockquote": true, "pre": true,
+	"table": true, "tr": true, "figure": true, "figcaption": true, "hr": true,
+}
+
+// htmlToText renders an HTML fragment as plain text, wrapped at textWidth columns.
+// Links are followed by their target and list items are prefixed with a bullet
+func htmlToText(fragment string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+
+	paragraphs := []string{}
+	current := strings.Builder{}
+	preformatted := 0
+	skip := 0
+	links := []string{}
+
+	flush := func() {
+		text := current.String()
+		current.Reset()
+		if preformatted == 0 {
+			text = strings.Join(strings.Fields(text), " ")
+		}
+		if strings.TrimSpace(text) != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			flush()
+			return wrapParagraphs(paragraphs)
+		case html.TextToken:
+			if skip == 0 {
+				current.Write(tokenizer.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			tag := string(name)
+			switch {
+			case tag == "script" || tag == "style":
+				skip++
+			case tag == "br":
+				if preformatted > 0 {
+					current.WriteString("\n")
+				} else {
+					flush()
+				}
+			case tag == "a":
+				href := ""
+				for hasAttr {
+					var key, value []byte
+					key, value, hasAttr = tokenizer.TagAttr()
+					if string(key) == "href" {
+						href = string(value)
+					}
+				}
+				links = append(links, href)
+			case tag == "img":
+				for hasAttr {
+					var key, value []byte
+					key, value, hasAttr = tokenizer.TagAttr()
+					if string(key) == "alt" && len(value) > 0 {
+						current.WriteString("[" + string(value) + "]")
+					}
+				}
+			case blockElements[tag]:
+				flush()
+				if tag == "pre" {
+					preformatted++
+				}
+				if tag == "li" {
+					current.WriteString("• ")
+				}
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			switch {
+			case tag == "script" || tag == "style":
+				skip = max(skip-1, 0)
+			case tag == "a":
+				if len(links) > 0 {
+					href := links[len(links)-1]
+					links = links[:len(links)-1]
+					if strings.HasPrefix(href, "http") && !strings.Contains(current.String(), href) {
+						current.WriteString(" (" + href + ")")
+					}
+				}
+			case blockElements[tag]:
+				flush()
+				if tag == "pre" {
+					preformatted = max(preformatted-1, 0)
+				}
+			}
+		}
+	}
+}
+
+// wrapParagraphs joins paragraphs with blank lines, wrapping lines longer than textWidth
+func wrapParagraphs(paragraphs []string) string {
+	wrapped := []string{}
+	for _, paragraph := range paragraphs {
+		if strings.Contains(paragraph, "\n") {
+			// preformatted text keeps its layout
+			wrapped = append(wrapped, strings.Trim(paragraph, "\n"))
+			continue
+		}
+		wrapped = append(wrapped, wrapLine(paragraph, textWidth))
+	}
+	return strings.Join(wrapped, "\n\n")
+}
+
+// wrapLine breaks a line on word boundaries so that no line exceeds width, unless a single word does
+func wrapLine(line string, width int) string {
+	lines := []string{}
+	current := ""
+	for _, word := range strings.Fields(line) {
+		if current != "" && len([]rune(current))+1+len([]rune(word)) > width {
+			lines = append(lines, current)
+			current = ""
+		}
+		if current == "" {
+			current = word
+		} else {
+			current += " " + word
+		}
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return strings.Join(lines, "\n")
+}
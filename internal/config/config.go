@@ -6,19 +6,83 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 )
 
 const configFileName = ".gatorconfig.json"
 
+const (
+	defaultMaxFeedFailures  = 10
+	defaultMinFetchInterval = 15 * time.Minute
+	defaultMaxFetchInterval = 24 * time.Hour
+	defaultDownloadDir      = "gator-downloads"
+)
+
 type Config struct {
-	DBURL           string `json:"db_url"`
-	CurrentUserName string `json:"current_user_name"`
+	DBURL            string `json:"db_url"`
+	CurrentUserName  string `json:"current_user_name"`
+	MaxFeedFailures  int    `json:"max_feed_failures,omitempty"`
+	MinFetchInterval string `json:"min_fetch_interval,omitempty"`
+	MaxFetchInterval string `json:"max_fetch_interval,omitempty"`
+	DownloadDir      string `json:"download_dir,omitempty"`
+	DownloadQuotaMB  int64  `json:"download_quota_mb,omitempty"`
 }
 
 func (cfg *Config) SetUser(user string) {
 	cfg.CurrentUserName = user
 }
 
+// FeedFailureLimit returns the number of consecutive failures after which a feed is disabled
+func (cfg *Config) FeedFailureLimit() int {
+	if cfg.MaxFeedFailures <= 0 {
+		return defaultMaxFeedFailures
+	}
+	return cfg.MaxFeedFailures
+}
+
+// FetchIntervalBounds returns the bounds of the interval between two fetches of a feed.
+// By default feeds are due again after 15 minutes, or later if the publisher hints so, and at most after a day
+func (cfg *Config) FetchIntervalBounds() (time.Duration, time.Duration, error) {
+	minInterval, maxInterval := defaultMinFetchInterval, defaultMaxFetchInterval
+
+	if cfg.MinFetchInterval != "" {
+		d, err := time.ParseDuration(cfg.MinFetchInterval)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid min_fetch_interval: %s", err)
+		}
+		minInterval = d
+	}
+	if cfg.MaxFetchInterval != "" {
+		d, err := time.ParseDuration(cfg.MaxFetchInterval)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid max_fetch_interval: %s", err)
+		}
+		maxInterval = d
+	}
+	if maxInterval < minInterval {
+		return 0, 0, fmt.Errorf("max_fetch_interval %s is shorter than min_fetch_interval %s", maxInterval, minInterval)
+	}
+
+	return minInterval, maxInterval, nil
+}
+
+// DownloadDirectory returns the directory enclosures are downloaded to, ~/gator-downloads by default
+func (cfg *Config) DownloadDirectory() (string, error) {
+	if cfg.DownloadDir != "" {
+		return cfg.DownloadDir, nil
+	}
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to read HomeDir: %s", err)
+	}
+	return homedir + "/" + defaultDownloadDir, nil
+}
+
+// DownloadQuotaBytes returns the disk quota of the download directory, 0 means unlimited
+func (cfg *Config) DownloadQuotaBytes() int64 {
+	return cfg.DownloadQuotaMB * 1024 * 1024
+}
+
 func ReadConfig() (*Config, error) {
 	homedir, err := os.UserHomeDir()
 	if err != nil {
@@ -62,4 +126,3 @@ func WriteConfig(cfg Config) error {
 	}
 	return nil
 }
-
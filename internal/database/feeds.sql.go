@@ -13,24 +13,89 @@ import (
 	"github.com/google/uuid"
 )
 
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+UPDATE feeds SET last_fetched_at = NOW(), updated_at = NOW(), next_fetch_at = $1
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE disabled_at IS NULL AND next_fetch_at <= NOW()
+    ORDER BY last_fetched_at NULLS FIRST
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, next_fetch_at, disabled_at, site_link, description, language, ttl, skip_hours, skip_days
+`
+
+type ClaimFeedsToFetchParams struct {
+	LeaseUntil time.Time
+	Limit      int32
+}
+
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch, arg.LeaseUntil, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+			&i.SiteLink,
+			&i.Description,
+			&i.Language,
+			&i.Ttl,
+			&i.SkipHours,
+			&i.SkipDays,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (created_at, updated_at, name, url, user_id, site_link, description, language)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7,
+    $8
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, next_fetch_at, disabled_at, site_link, description, language, ttl, skip_hours, skip_days
 `
 
 type CreateFeedParams struct {
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      sql.NullString
-	Url       sql.NullString
-	UserID    uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        sql.NullString
+	Url         sql.NullString
+	UserID      uuid.UUID
+	SiteLink    sql.NullString
+	Description sql.NullString
+	Language    sql.NullString
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -40,6 +105,9 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.SiteLink,
+		arg.Description,
+		arg.Language,
 	)
 	var i Feed
 	err := row.Scan(
@@ -50,6 +118,17 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.Ttl,
+		&i.SkipHours,
+		&i.SkipDays,
 	)
 	return i, err
 }
@@ -59,7 +138,7 @@ const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH inserted AS (
     INSERT INTO feed_follows (user_id, feed_id)
     VALUES ($1, $2)
-    RETURNING id, created_at, updated_at, user_id, feed_id
+    RETURNING id, created_at, updated_at, user_id, feed_id, category
 )
 SELECT
     i.id,
@@ -119,6 +198,18 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 	return err
 }
 
+const enableFeed = `-- name: EnableFeed :execrows
+UPDATE feeds SET disabled_at = NULL, consecutive_failures = 0, next_fetch_at = NOW() WHERE url = $1
+`
+
+func (q *Queries) EnableFeed(ctx context.Context, url sql.NullString) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableFeed, url)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getFeed = `-- name: GetFeed :one
 
 SELECT
@@ -153,28 +244,87 @@ func (q *Queries) GetFeed(ctx context.Context, url sql.NullString) (GetFeedRow,
 	return i, err
 }
 
+const getFeedFollowsForExport = `-- name: GetFeedFollowsForExport :many
+SELECT
+    f.name,
+    f.url,
+    f.site_link,
+    ff.category
+FROM feed_follows ff
+JOIN feeds f ON ff.feed_id = f.id
+WHERE ff.user_id = $1
+ORDER BY ff.category NULLS FIRST, f.name
+`
+
+type GetFeedFollowsForExportRow struct {
+	Name     sql.NullString
+	Url      sql.NullString
+	SiteLink sql.NullString
+	Category sql.NullString
+}
+
+func (q *Queries) GetFeedFollowsForExport(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForExportRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowsForExport, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedFollowsForExportRow
+	for rows.Next() {
+		var i GetFeedFollowsForExportRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.SiteLink,
+			&i.Category,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
 SELECT
-    f.name
+    f.name,
+    f.url,
+    COUNT(p.id) FILTER (WHERE pr.post_id IS NULL) AS unread
 FROM feed_follows ff
 JOIN feeds f ON ff.feed_id = f.id
 JOIN users u ON ff.user_id = u.id
+LEFT JOIN posts p ON p.feed_id = f.id
+LEFT JOIN post_reads pr ON pr.post_id = p.id AND pr.user_id = u.id
 WHERE u.name = $1
+GROUP BY f.id
+ORDER BY f.name
 `
 
-func (q *Queries) GetFeedFollowsForUser(ctx context.Context, name string) ([]sql.NullString, error) {
+type GetFeedFollowsForUserRow struct {
+	Name   sql.NullString
+	Url    sql.NullString
+	Unread int64
+}
+
+func (q *Queries) GetFeedFollowsForUser(ctx context.Context, name string) ([]GetFeedFollowsForUserRow, error) {
 	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, name)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []sql.NullString
+	var items []GetFeedFollowsForUserRow
 	for rows.Next() {
-		var name sql.NullString
-		if err := rows.Scan(&name); err != nil {
+		var i GetFeedFollowsForUserRow
+		if err := rows.Scan(&i.Name, &i.Url, &i.Unread); err != nil {
 			return nil, err
 		}
-		items = append(items, name)
+		items = append(items, i)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -191,16 +341,19 @@ SELECT
     feeds.id,
     feeds.name,
     feeds.url,
+    feeds.site_link,
     feeds.user_id,
     users.name AS user_name
     FROM feeds
 JOIN users  ON feeds.user_id = users.id
+ORDER BY feeds.id
 `
 
 type GetFeedsRow struct {
 	ID       int32
 	Name     sql.NullString
 	Url      sql.NullString
+	SiteLink sql.NullString
 	UserID   uuid.UUID
 	UserName string
 }
@@ -218,6 +371,7 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 			&i.ID,
 			&i.Name,
 			&i.Url,
+			&i.SiteLink,
 			&i.UserID,
 			&i.UserName,
 		); err != nil {
@@ -236,7 +390,8 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
 
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, next_fetch_at, disabled_at, site_link, description, language, ttl, skip_hours, skip_days FROM feeds
+WHERE disabled_at IS NULL AND next_fetch_at <= NOW()
 ORDER BY last_fetched_at NULLS FIRST
 LIMIT 1
 `
@@ -252,10 +407,56 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.NextFetchAt,
+		&i.DisabledAt,
+		&i.SiteLink,
+		&i.Description,
+		&i.Language,
+		&i.Ttl,
+		&i.SkipHours,
+		&i.SkipDays,
 	)
 	return i, err
 }
 
+const markFeedFetchFailed = `-- name: MarkFeedFetchFailed :exec
+UPDATE feeds SET consecutive_failures = $2, next_fetch_at = $3, disabled_at = $4 WHERE id = $1
+`
+
+type MarkFeedFetchFailedParams struct {
+	ID                  int32
+	ConsecutiveFailures int32
+	NextFetchAt         time.Time
+	DisabledAt          sql.NullTime
+}
+
+func (q *Queries) MarkFeedFetchFailed(ctx context.Context, arg MarkFeedFetchFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedFetchFailed,
+		arg.ID,
+		arg.ConsecutiveFailures,
+		arg.NextFetchAt,
+		arg.DisabledAt,
+	)
+	return err
+}
+
+const markFeedFetchSucceeded = `-- name: MarkFeedFetchSucceeded :exec
+UPDATE feeds SET consecutive_failures = 0, next_fetch_at = $2 WHERE id = $1
+`
+
+type MarkFeedFetchSucceededParams struct {
+	ID          int32
+	NextFetchAt time.Time
+}
+
+func (q *Queries) MarkFeedFetchSucceeded(ctx context.Context, arg MarkFeedFetchSucceededParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedFetchSucceeded, arg.ID, arg.NextFetchAt)
+	return err
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds SET last_fetched_at = NOW() WHERE id = $1
 `
@@ -264,3 +465,56 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id int32) error {
 	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
 	return err
 }
+
+const updateFeedHTTPCache = `-- name: UpdateFeedHTTPCache :exec
+UPDATE feeds SET etag = $2, last_modified = $3 WHERE id = $1
+`
+
+type UpdateFeedHTTPCacheParams struct {
+	ID           int32
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) UpdateFeedHTTPCache(ctx context.Context, arg UpdateFeedHTTPCacheParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedHTTPCache, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const updateFeedScheduleHints = `-- name: UpdateFeedScheduleHints :exec
+UPDATE feeds SET ttl = $2, skip_hours = $3, skip_days = $4 WHERE id = $1
+`
+
+type UpdateFeedScheduleHintsParams struct {
+	ID        int32
+	Ttl       sql.NullString
+	SkipHours sql.NullString
+	SkipDays  sql.NullString
+}
+
+func (q *Queries) UpdateFeedScheduleHints(ctx context.Context, arg UpdateFeedScheduleHintsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedScheduleHints,
+		arg.ID,
+		arg.Ttl,
+		arg.SkipHours,
+		arg.SkipDays,
+	)
+	return err
+}
+
+const upsertFeedFollow = `-- name: UpsertFeedFollow :exec
+INSERT INTO feed_follows (user_id, feed_id, category)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, feed_id) DO UPDATE SET category = EXCLUDED.category
+`
+
+type UpsertFeedFollowParams struct {
+	UserID   uuid.UUID
+	FeedID   int32
+	Category sql.NullString
+}
+
+func (q *Queries) UpsertFeedFollow(ctx context.Context, arg UpsertFeedFollowParams) error {
+	_, err := q.db.ExecContext(ctx, upsertFeedFollow, arg.UserID, arg.FeedID, arg.Category)
+	return err
+}
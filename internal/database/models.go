@@ -12,13 +12,37 @@ import (
 )
 
 type Feed struct {
-	ID            int32
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          sql.NullString
-	Url           sql.NullString
-	UserID        uuid.UUID
-	LastFetchedAt time.Time
+	ID                  int32
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Name                sql.NullString
+	Url                 sql.NullString
+	UserID              uuid.UUID
+	LastFetchedAt       time.Time
+	Etag                sql.NullString
+	LastModified        sql.NullString
+	ConsecutiveFailures int32
+	NextFetchAt         time.Time
+	DisabledAt          sql.NullTime
+	SiteLink            sql.NullString
+	Description         sql.NullString
+	Language            sql.NullString
+	Ttl                 sql.NullString
+	SkipHours           sql.NullString
+	SkipDays            sql.NullString
+}
+
+type FeedFetch struct {
+	ID           int32
+	FeedID       int32
+	StartedAt    time.Time
+	DurationMs   int32
+	HttpStatus   sql.NullInt32
+	Bytes        int64
+	ItemsSeen    int32
+	NewPosts     int32
+	Error        sql.NullString
+	UpdatedPosts int32
 }
 
 type FeedFollow struct {
@@ -27,6 +51,7 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    int32
+	Category  sql.NullString
 }
 
 type Post struct {
@@ -34,10 +59,36 @@ type Post struct {
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	Title       sql.NullString
-	Url         string
+	Url         sql.NullString
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      int32
+	Author      sql.NullString
+	Guid        string
+	ContentHash sql.NullString
+	Content     sql.NullString
+	Search      interface{}
+}
+
+type PostEnclosure struct {
+	ID               int32
+	CreatedAt        time.Time
+	PostID           int32
+	Url              string
+	MimeType         sql.NullString
+	Length           sql.NullInt64
+	Duration         sql.NullInt32
+	DownloadStatus   sql.NullString
+	DownloadPath     sql.NullString
+	DownloadedBytes  int64
+	DownloadedAt     sql.NullTime
+	DownloadAttempts int32
+}
+
+type PostRead struct {
+	UserID uuid.UUID
+	PostID int32
+	ReadAt time.Time
 }
 
 type User struct {
@@ -46,3 +97,9 @@ type User struct {
 	UpdatedAt time.Time
 	Name      string
 }
+
+type UserStar struct {
+	UserID    uuid.UUID
+	PostID    int32
+	StarredAt time.Time
+}
@@ -13,41 +13,85 @@ import (
 	"github.com/google/uuid"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES (
-    $1,
-    $2,
-    $3,
-    $4,
-    $5,
-    $6,
-    $7
+const adoptLegacyPostGUID = `-- name: AdoptLegacyPostGUID :execrows
+UPDATE posts
+SET guid = $1
+WHERE feed_id = $2
+  AND url = $3
+  AND guid = url
+  AND content_hash IS NULL
+  AND guid <> $1
+  AND NOT EXISTS (
+    SELECT 1 FROM posts other WHERE other.feed_id = $2 AND other.guid = $1
+  )
+`
+
+type AdoptLegacyPostGUIDParams struct {
+	Guid   string
+	FeedID int32
+	Url    sql.NullString
+}
+
+func (q *Queries) AdoptLegacyPostGUID(ctx context.Context, arg AdoptLegacyPostGUIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, adoptLegacyPostGUID, arg.Guid, arg.FeedID, arg.Url)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const feedHasLegacyPosts = `-- name: FeedHasLegacyPosts :one
+SELECT EXISTS (
+    SELECT 1 FROM posts WHERE feed_id = $1 AND guid = url AND content_hash IS NULL
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
 `
 
-type CreatePostParams struct {
+func (q *Queries) FeedHasLegacyPosts(ctx context.Context, feedID int32) (bool, error) {
+	row := q.db.QueryRowContext(ctx, feedHasLegacyPosts, feedID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const getPost = `-- name: GetPost :one
+SELECT
+    p.id,
+    p.created_at,
+    p.updated_at,
+    p.title,
+    p.url,
+    p.description,
+    p.published_at,
+    p.feed_id,
+    p.author,
+    p.guid,
+    p.content_hash,
+    p.content,
+    f.name AS feed_name
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+WHERE p.id = $1
+`
+
+type GetPostRow struct {
+	ID          int32
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	Title       sql.NullString
-	Url         string
+	Url         sql.NullString
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      int32
+	Author      sql.NullString
+	Guid        string
+	ContentHash sql.NullString
+	Content     sql.NullString
+	FeedName    sql.NullString
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
-		arg.FeedID,
-	)
-	var i Post
+func (q *Queries) GetPost(ctx context.Context, id int32) (GetPostRow, error) {
+	row := q.db.QueryRowContext(ctx, getPost, id)
+	var i GetPostRow
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
@@ -57,6 +101,11 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Author,
+		&i.Guid,
+		&i.ContentHash,
+		&i.Content,
+		&i.FeedName,
 	)
 	return i, err
 }
@@ -71,18 +120,37 @@ SELECT
     p.description,
     p.published_at,
     p.feed_id,
-    f.name AS feed_name
+    p.author,
+    f.name AS feed_name,
+    (pr.post_id IS NOT NULL)::boolean AS read
 FROM posts p
 JOIN feeds f ON p.feed_id = f.id
 JOIN feed_follows ff ON f.id = ff.feed_id
+LEFT JOIN post_reads pr ON pr.post_id = p.id AND pr.user_id = ff.user_id
 WHERE ff.user_id = $1
-ORDER BY p.published_at DESC NULLS LAST
-LIMIT $2
+  AND (NOT $2::boolean OR pr.post_id IS NULL)
+  AND ($3::text IS NULL OR f.url = $3 OR f.name = $3)
+  AND ($4::timestamptz IS NULL
+    OR (CASE WHEN $5::text = 'ingested' THEN p.created_at ELSE p.published_at END) >= $4)
+  AND ($6::timestamptz IS NULL
+    OR (CASE WHEN $5::text = 'ingested' THEN p.created_at ELSE p.published_at END) < $6)
+ORDER BY
+    CASE WHEN $5::text = 'ingested' THEN p.created_at END DESC,
+    p.published_at DESC NULLS LAST,
+    p.id DESC
+LIMIT $7
+OFFSET $8
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID     uuid.UUID
+	UnreadOnly bool
+	Feed       sql.NullString
+	Since      sql.NullTime
+	OrderBy    string
+	Until      sql.NullTime
+	Limit      int32
+	Offset     int32
 }
 
 type GetPostsForUserRow struct {
@@ -90,15 +158,26 @@ type GetPostsForUserRow struct {
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	Title       sql.NullString
-	Url         string
+	Url         sql.NullString
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      int32
+	Author      sql.NullString
 	FeedName    sql.NullString
+	Read        bool
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.Feed,
+		arg.Since,
+		arg.OrderBy,
+		arg.Until,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +194,9 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Author,
 			&i.FeedName,
+			&i.Read,
 		); err != nil {
 			return nil, err
 		}
@@ -129,3 +210,147 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.published_at,
+    f.name AS feed_name,
+    ts_rank(p.search, query)::real AS rank,
+    ts_headline('english', coalesce(p.content, p.description, ''), query,
+        'MaxFragments=2, MaxWords=25, MinWords=10, StartSel=**, StopSel=**')::text AS headline
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON f.id = ff.feed_id,
+    websearch_to_tsquery('english', $1) query
+WHERE ff.user_id = $2
+  AND p.search @@ query
+  AND ($3::text IS NULL OR f.url = $3)
+  AND ($4::timestamptz IS NULL OR p.published_at >= $4)
+ORDER BY rank DESC, p.published_at DESC NULLS LAST
+LIMIT $5
+`
+
+type SearchPostsForUserParams struct {
+	Query   string
+	UserID  uuid.UUID
+	FeedUrl sql.NullString
+	Since   sql.NullTime
+	Limit   int32
+}
+
+type SearchPostsForUserRow struct {
+	ID          int32
+	Title       sql.NullString
+	Url         sql.NullString
+	PublishedAt sql.NullTime
+	FeedName    sql.NullString
+	Rank        float32
+	Headline    string
+}
+
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser,
+		arg.Query,
+		arg.UserID,
+		arg.FeedUrl,
+		arg.Since,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Headline,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertPost = `-- name: UpsertPost :one
+INSERT INTO posts (created_at, updated_at, title, url, description, published_at, feed_id, author, guid, content_hash, content)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
+)
+ON CONFLICT (feed_id, guid) DO UPDATE SET
+    updated_at = CASE WHEN posts.content_hash IS NULL THEN posts.updated_at ELSE EXCLUDED.updated_at END,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = EXCLUDED.published_at,
+    author = EXCLUDED.author,
+    content = EXCLUDED.content,
+    content_hash = EXCLUDED.content_hash
+WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+RETURNING id, (xmax = 0) AS inserted, (updated_at = $2) AS changed
+`
+
+type UpsertPostParams struct {
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         sql.NullString
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      int32
+	Author      sql.NullString
+	Guid        string
+	ContentHash sql.NullString
+	Content     sql.NullString
+}
+
+type UpsertPostRow struct {
+	ID       int32
+	Inserted bool
+	Changed  bool
+}
+
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (UpsertPostRow, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Author,
+		arg.Guid,
+		arg.ContentHash,
+		arg.Content,
+	)
+	var i UpsertPostRow
+	err := row.Scan(&i.ID, &i.Inserted, &i.Changed)
+	return i, err
+}
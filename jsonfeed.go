@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	Description string           `json:"description"`
+	Language    string           `json:"language"`
+	Authors     []JSONFeedAuthor `json:"authors"`
+	Author      *JSONFeedAuthor  `json:"author"`
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// JSONFeedID is an item id. The spec requires a string, but some feeds publish numbers
+type JSONFeedID string
+
+func (id *JSONFeedID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = JSONFeedID(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = JSONFeedID(n.String())
+	return nil
+}
+
+type JSONFeedAttachment struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+type JSONFeedItem struct {
+	ID            JSONFeedID           `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Authors       []JSONFeedAuthor     `json:"authors"`
+	Author        *JSONFeedAuthor      `json:"author"`
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+// isJSONFeed reports whether a response should be parsed as JSON Feed
+func isJSONFeed(contentType string, body []byte) bool {
+	if strings.Contains(contentType, "json") {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSpace(string(body)), "{")
+}
+
+// parseJSONFeed decodes a JSON Feed 1.0/1.1 document into the RSSFeed shape
+func parseJSONFeed(body []byte) (*RSSFeed, error) {
+	jsonFeed := JSONFeed{}
+	if err := json.Unmarshal(body, &jsonFeed); err != nil {
+		return &RSSFeed{}, err
+	}
+	return jsonFeed.toRSSFeed(), nil
+}
+
+// jsonFeedAuthorNames joins the author names, falling back to the deprecated 1.0 author field
+func jsonFeedAuthorNames(authors []JSONFeedAuthor, author *JSONFeedAuthor) string {
+	if len(authors) == 0 && author != nil {
+		authors = []JSONFeedAuthor{*author}
+	}
+
+	names := []string{}
+	for _, a := range authors {
+		if a.Name != "" {
+			names = append(names, a.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// toRSSFeed maps a JSON Feed onto the RSSFeed shape used by scrapeFeeds
+func (j *JSONFeed) toRSSFeed() *RSSFeed {
+	feed := &RSSFeed{}
+	feed.Channel.Title = j.Title
+	feed.Channel.Link = j.HomePageURL
+	feed.Channel.Description = j.Description
+	feed.Channel.Language = j.Language
+
+	feedAuthor := jsonFeedAuthorNames(j.Authors, j.Author)
+
+	for _, item := range j.Items {
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+
+		content := item.ContentHTML
+		if content == "" {
+			content = item.ContentText
+		}
+		description := content
+		if description == "" {
+			description = item.Summary
+		}
+
+		pubDate := item.DatePublished
+		if pubDate == "" {
+			pubDate = item.DateModified
+		}
+
+		author := jsonFeedAuthorNames(item.Authors, item.Author)
+		if author == "" {
+			author = feedAuthor
+		}
+
+		enclosures := []RSSEnclosure{}
+		for _, attachment := range item.Attachments {
+			enclosure := RSSEnclosure{URL: attachment.URL, Type: attachment.MimeType}
+			if attachment.SizeInBytes > 0 {
+				enclosure.Length = strconv.FormatInt(attachment.SizeInBytes, 10)
+			}
+			if attachment.DurationInSeconds > 0 {
+				enclosure.Duration = strconv.FormatFloat(attachment.DurationInSeconds, 'f', -1, 64)
+			}
+			enclosures = append(enclosures, enclosure)
+		}
+
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        link,
+			Description: description,
+			PubDate:     pubDate,
+			Author:      author,
+			GUID:        string(item.ID),
+			Content:     content,
+			Enclosures:  enclosures,
+		})
+	}
+
+	return feed
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
@@ -13,6 +17,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/deoreal/gator/internal/config"
@@ -25,80 +30,249 @@ const configFileName = ".gatorconfig.json"
 
 var time_between_reqs = "10s"
 
-type state struct {
-	conf *config.Config
-	db   *database.Queries
-}
+const defaultAggConcurrency = 4
 
-type command struct {
-	name string
-	args []string
-}
+// number of enclosures downloaded per agg tick
+const defaultDownloadBatch = 5
 
-type commands struct {
-	handlers map[string]func(*state, command) error
-}
+// bounds of the exponential backoff applied to failing feeds
+const (
+	minFetchBackoff = time.Minute
+	maxFetchBackoff = 24 * time.Hour
+)
+
+// fetchLease is how long a claimed feed stays reserved for the agg process that claimed it. The
+// outcome of the fetch reschedules it, and a process that dies mid-fetch loses the feed after this
+const fetchLease = 10 * time.Minute
 
-type Feed struct {
-	ID        int       `json:"title"`
-	CreatedAt time.Time `json:"create_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Name      string    `json:"name"`
-	URL       string    `json:"url"`
-	UserID    uuid.UUID `json:"user_id"`
+// feedFetchTimeout bounds the fetch of a single feed. It is shorter than fetchLease, so a stalled
+// server releases the feed before another process could claim it
+const feedFetchTimeout = 2 * time.Minute
+
+type state struct {
+	conf *config.Config
+	db   *database.Queries
+	// output format of the listing commands, set with --output
+	output string
 }
 
 type RSSFeed struct {
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		// itunes:title elements must not be matched by Title
+		ITunesTitle string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd title"`
+		Title       string `xml:"title"`
+		// atom:link elements must not be matched by Link
+		AtomLinks   []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+		Link        string     `xml:"link"`
+		Description string     `xml:"description"`
+		Language    string     `xml:"language"`
+		TTL         string     `xml:"ttl"`
+		SkipHours   []string   `xml:"skipHours>hour"`
+		SkipDays    []string   `xml:"skipDays>day"`
+		Item        []RSSItem  `xml:"item"`
 	} `xml:"channel"`
 }
 
 type RSSItem struct {
+	// the iTunes and Media RSS elements of the same name must not be matched by Title, Description
+	// and Author
+	ITunesTitle      string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd title"`
+	ITunesAuthor     string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+	MediaTitle       string `xml:"http://search.yahoo.com/mrss/ title"`
+	MediaDescription string `xml:"http://search.yahoo.com/mrss/ description"`
+
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	Author      string `xml:"author"`
+	GUID        string `xml:"guid"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+
+	Enclosures     []RSSEnclosure `xml:"enclosure"`
+	MediaContents  []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups    []MediaGroup   `xml:"http://search.yahoo.com/mrss/ group"`
+	ITunesDuration string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+}
+
+// postContentHash returns a hash of the stored content of a post, used to detect edited items
+func postContentHash(post database.UpsertPostParams, enclosures []RSSEnclosure) string {
+	fields := []string{
+		post.Title.String,
+		post.Url.String,
+		post.Description.String,
+		post.Content.String,
+		post.Author.String,
+		post.PublishedAt.Time.UTC().Format(time.RFC3339),
+	}
+	for _, enclosure := range enclosures {
+		fields = append(fields, enclosure.URL, enclosure.Type, enclosure.Length, enclosure.Duration)
+	}
+
+	hash := sha256.New()
+	for _, field := range fields {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// postGUID returns the identifier of a feed item, falling back to a hash of its link and title
+func postGUID(item RSSItem) string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	sum := sha256.Sum256([]byte(item.Link + "\n" + item.Title))
+	return hex.EncodeToString(sum[:])
+}
+
+// feedResponse holds the HTTP metadata of a feed fetch and the publisher's scheduling hints
+type feedResponse struct {
+	StatusCode   int
+	ETag         string
+	LastModified string
+	Bytes        int64
+	Hints        scheduleHints
+}
+
+// NotModified reports whether the server answered a conditional request with 304
+func (r feedResponse) NotModified() bool {
+	return r.StatusCode == http.StatusNotModified
 }
 
 // fetchFeed reads a RSSfeed from a given url
 func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	feed, _, err := fetchFeedConditional(ctx, feedURL, "", "")
+	return feed, err
+}
+
+// fetchFeedConditional reads a RSSfeed from a given url, sending the validators of the last
+// successful fetch. A 304 response yields an empty feed and a NotModified feedResponse
+func fetchFeedConditional(ctx context.Context, feedURL, etag, lastModified string) (*RSSFeed, feedResponse, error) {
 	client := &http.Client{
 		CheckRedirect: http.DefaultClient.CheckRedirect,
 	}
 
-	req, err := http.NewRequest("GET", feedURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
-		return &RSSFeed{}, err
+		return &RSSFeed{}, feedResponse{}, err
 	}
 
 	req.Header.Set("User-Agent", "gator")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return &RSSFeed{}, err
+		return &RSSFeed{}, feedResponse{}, err
 	}
 
 	defer resp.Body.Close()
 
+	meta := newFeedResponse(resp)
+	if meta.NotModified() {
+		return &RSSFeed{}, meta, nil
+	}
+	if resp.StatusCode >= 400 {
+		return &RSSFeed{}, meta, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return &RSSFeed{}, err
+		return &RSSFeed{}, meta, err
 	}
 
-	xmldata := RSSFeed{}
+	xmldata, err := decodeFeed(resp.Header.Get("Content-Type"), body, &meta)
+	return xmldata, meta, err
+}
 
-	err = xml.Unmarshal(body, &xmldata)
+// newFeedResponse reads the HTTP metadata and the scheduling hints of the headers of a feed response
+func newFeedResponse(resp *http.Response) feedResponse {
+	meta := feedResponse{
+		StatusCode:   resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	headerScheduleHints(resp.Header, time.Now(), &meta.Hints)
+	return meta
+}
+
+// decodeFeed parses the body of a feed response and adds its size and the channel's scheduling hints to meta
+func decodeFeed(contentType string, body []byte, meta *feedResponse) (*RSSFeed, error) {
+	meta.Bytes = int64(len(body))
+
+	xmldata, err := parseFeed(contentType, body)
 	if err != nil {
 		return &RSSFeed{}, err
 	}
 
 	xmldata.Channel.Title = html.UnescapeString(xmldata.Channel.Title)
 	xmldata.Channel.Description = html.UnescapeString((xmldata.Channel.Description))
+	channelScheduleHints(xmldata, &meta.Hints)
+
+	return xmldata, nil
+}
+
+// parseFeed decodes a JSON Feed or a XML feed document based on its root element
+func parseFeed(contentType string, body []byte) (*RSSFeed, error) {
+	if isJSONFeed(contentType, body) {
+		return parseJSONFeed(body)
+	}
+
+	root, err := feedRootElement(body)
+	if err != nil {
+		return &RSSFeed{}, err
+	}
+
+	switch root.Local {
+	case "rss":
+		xmldata := RSSFeed{}
+		if err := xml.Unmarshal(body, &xmldata); err != nil {
+			return &RSSFeed{}, err
+		}
+		// podcast items may only carry the iTunes title and author
+		for i := range xmldata.Channel.Item {
+			item := &xmldata.Channel.Item[i]
+			if item.Title == "" {
+				item.Title = item.ITunesTitle
+			}
+			if item.Author == "" {
+				item.Author = item.ITunesAuthor
+			}
+		}
+		return &xmldata, nil
+	case "feed":
+		atom := AtomFeed{}
+		if err := xml.Unmarshal(body, &atom); err != nil {
+			return &RSSFeed{}, err
+		}
+		return atom.toRSSFeed(), nil
+	case "RDF":
+		rdf := RDFFeed{}
+		if err := xml.Unmarshal(body, &rdf); err != nil {
+			return &RSSFeed{}, err
+		}
+		return rdf.toRSSFeed(), nil
+	default:
+		return &RSSFeed{}, fmt.Errorf("unsupported feed format: <%s>", root.Local)
+	}
+}
 
-	return &xmldata, nil
+// feedRootElement returns the name of the first element of a XML document
+func feedRootElement(body []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, fmt.Errorf("failed to find root element: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
 }
 
 // middlewareLoggedIn used to enrich a handler call with needed information
@@ -137,11 +311,6 @@ func getConfigFilePath() (string, error) {
 
 // handlerLogin set a user as current user
 func handlerLogin(s *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		fmt.Println("username is required")
-		os.Exit(1)
-	}
-
 	users, err := s.db.GetUsers(context.Background())
 	if err != nil {
 		return err
@@ -152,17 +321,46 @@ func handlerLogin(s *state, cmd command) error {
 		config.WriteConfig(*s.conf)
 		fmt.Println("user has been set to: ", cmd.args[0])
 	} else {
-		return fmt.Errorf("unknown user")
+		return fmt.Errorf("unknown user: %s", cmd.args[0])
 	}
 	return nil
 }
 
+// handlerFeeds lists all feeds with the user who added them
 func handlerFeeds(s *state, cmd command) error {
 	feeds, err := s.db.GetFeeds(context.Background())
 	if err != nil {
 		return err
 	}
-	fmt.Println(feeds)
+
+	switch s.output {
+	case outputJSON:
+		items := make([]feedJSON, 0, len(feeds))
+		for _, feed := range feeds {
+			items = append(items, feedJSON{
+				ID:      feed.ID,
+				Name:    feed.Name.String,
+				URL:     feed.Url.String,
+				SiteURL: jsonString(feed.SiteLink),
+				AddedBy: feed.UserName,
+			})
+		}
+		return writeJSON(items)
+	case outputTable:
+		rows := [][]string{}
+		for _, feed := range feeds {
+			rows = append(rows, []string{strconv.Itoa(int(feed.ID)), feed.Name.String, feed.Url.String, feed.UserName})
+		}
+		return writeTable([]string{"ID", "NAME", "URL", "ADDED BY"}, rows)
+	}
+
+	if len(feeds) == 0 {
+		fmt.Println("No feeds found")
+		return nil
+	}
+	for _, feed := range feeds {
+		fmt.Printf("- %s (%s), added by %s\n", feed.Name.String, feed.Url.String, feed.UserName)
+	}
 	return nil
 }
 
@@ -172,6 +370,26 @@ func handlerUsers(s *state, cmd command) error {
 	if err != nil {
 		return err
 	}
+
+	switch s.output {
+	case outputJSON:
+		items := make([]userJSON, 0, len(users))
+		for _, user := range users {
+			items = append(items, userJSON{Name: user, Current: user == s.conf.CurrentUserName})
+		}
+		return writeJSON(items)
+	case outputTable:
+		rows := [][]string{}
+		for _, user := range users {
+			current := ""
+			if user == s.conf.CurrentUserName {
+				current = "*"
+			}
+			rows = append(rows, []string{user, current})
+		}
+		return writeTable([]string{"NAME", "CURRENT"}, rows)
+	}
+
 	for _, user := range users {
 		if user == s.conf.CurrentUserName {
 			fmt.Println(user, "(current)")
@@ -185,12 +403,6 @@ func handlerUsers(s *state, cmd command) error {
 
 // handlerRegister registers a new user by adding him to the database and setting it as the current user
 func handlerRegister(s *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		fmt.Println("username is required")
-		os.Exit(1)
-
-	}
-
 	users, err := s.db.GetUsers(context.Background())
 	if err != nil {
 		return err
@@ -212,48 +424,96 @@ func handlerRegister(s *state, cmd command) error {
 	return nil
 }
 
-// handlerAgg lists an RSSfeed target
+// handlerAgg scrapes feeds in batches of concurrency feeds at every tick. With --download it also
+// downloads the enclosures of the current user's feeds after each batch
 func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) == 1 {
+	download := cmd.boolFlag("download")
+
+	if len(cmd.args) >= 1 {
 		time_between_reqs = cmd.args[0]
 	}
 
-	t, err := time.ParseDuration(time_between_reqs)
-	if err != nil {
-		return err
+	concurrency := defaultAggConcurrency
+	if len(cmd.args) >= 2 {
+		parsed, err := strconv.Atoi(cmd.args[1])
+		if err != nil || parsed < 1 {
+			return usageErrorf("invalid concurrency: %s", cmd.args[1])
+		}
+		concurrency = parsed
 	}
-	fmt.Println("Collecting feeds every:", t)
-	feedURL := "https://www.wagslane.dev/index.xml"
 
-	rssFeed, err := fetchFeed(context.Background(), feedURL)
+	timeBetweenRequests, err := time.ParseDuration(time_between_reqs)
 	if err != nil {
+		return usageErrorf("invalid interval: %s", time_between_reqs)
+	}
+	if _, _, err := s.conf.FetchIntervalBounds(); err != nil {
 		return err
 	}
 
-	fmt.Println(rssFeed)
-	timeBetweenRequests, _ := time.ParseDuration(time_between_reqs)
+	var user database.User
+	if download {
+		userID, err := s.db.GetUser(context.Background(), s.conf.CurrentUserName)
+		if err != nil {
+			return fmt.Errorf("downloading requires a logged in user: %w", err)
+		}
+		user, err = s.db.GetUserByID(context.Background(), userID)
+		if err != nil {
+			return fmt.Errorf("couldn't get user details: %w", err)
+		}
+	}
+	fmt.Printf("Collecting up to %d feeds every: %v\n", concurrency, timeBetweenRequests)
+
+	// downloads run on their own, so large or stalled downloads don't hold up scraping
+	if download {
+		go func() {
+			ticker := time.NewTicker(timeBetweenRequests)
+			for ; ; <-ticker.C {
+				if _, err := downloadEnclosures(s, user, defaultDownloadBatch); err != nil {
+					log.Println(err)
+				}
+			}
+		}()
+	}
+
 	ticker := time.NewTicker(timeBetweenRequests)
 	for ; ; <-ticker.C {
-		scrapeFeeds(s)
+		if err := scrapeFeedsConcurrently(s, concurrency); err != nil {
+			log.Println(err)
+		}
 	}
 }
 
 // handlerFollowing lists the feeds a user is assigned to
 func handlerFollowing(s *state, cmd command, user database.User) error {
-	feedNames, err := s.db.GetFeedFollowsForUser(context.Background(), user.Name)
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.Name)
 	if err != nil {
 		return fmt.Errorf("couldn't get feeds for user: %w", err)
 	}
 
-	if len(feedNames) == 0 {
+	switch s.output {
+	case outputJSON:
+		items := make([]followingJSON, 0, len(follows))
+		for _, follow := range follows {
+			items = append(items, followingJSON{Name: follow.Name.String, URL: follow.Url.String, Unread: follow.Unread})
+		}
+		return writeJSON(items)
+	case outputTable:
+		rows := [][]string{}
+		for _, follow := range follows {
+			rows = append(rows, []string{follow.Name.String, follow.Url.String, strconv.FormatInt(follow.Unread, 10)})
+		}
+		return writeTable([]string{"NAME", "URL", "UNREAD"}, rows)
+	}
+
+	if len(follows) == 0 {
 		fmt.Println("No feeds found for the current user")
 		return nil
 	}
 
 	fmt.Printf("Feeds followed by %s:\n", user.Name)
-	for _, feedName := range feedNames {
-		if feedName.Valid {
-			fmt.Printf("- %s\n", feedName.String)
+	for _, follow := range follows {
+		if follow.Name.Valid {
+			fmt.Printf("- %s (%d unread)\n", follow.Name.String, follow.Unread)
 		}
 	}
 
@@ -262,11 +522,6 @@ func handlerFollowing(s *state, cmd command, user database.User) error {
 
 // handlerFollow add a user to the list of followers
 func handlerFollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) < 1 {
-		fmt.Println("feed url is required")
-		os.Exit(1)
-	}
-
 	feedURL := sql.NullString{String: cmd.args[0], Valid: true}
 
 	feed, err := s.db.GetFeed(context.Background(), feedURL)
@@ -274,37 +529,63 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 		return err
 	}
 
-	cff, err := s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{UserID: user.ID, FeedID: feed.ID})
+	feedFollow, err := s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{UserID: user.ID, FeedID: feed.ID})
 	if err != nil {
 		return err
 	}
-	fmt.Println(cff)
+	fmt.Printf("User %s is now following %s\n", feedFollow.UserName, feedFollow.FeedName.String)
 	return nil
 }
 
-// handlerAddFeed adds a feed to the feeds table
+// handlerAddFeed validates a feed, adds it to the feeds table and ingests its current posts.
+// It takes either a url, named after the channel title, or a name and a url
 func handlerAddFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.args) < 2 {
-		fmt.Println("name and url are required")
-		os.Exit(1)
+	name, pageURL := "", cmd.args[0]
+	if len(cmd.args) >= 2 {
+		name, pageURL = cmd.args[0], cmd.args[1]
+	}
+
+	// The URL may be a site's page rather than its feed. The feed is validated by parsing it
+	feedURL, feedContent, meta, err := discoverFeed(context.Background(), pageURL)
+	if err != nil {
+		return fmt.Errorf("couldn't find a feed for %s: %w", pageURL, err)
+	}
+
+	if name == "" {
+		name = strings.TrimSpace(feedContent.Channel.Title)
+	}
+	if name == "" {
+		return fmt.Errorf("feed %s has no title, a name is required", feedURL)
 	}
 
-	n := sql.NullString{String: cmd.args[0], Valid: true}
-	u := sql.NullString{String: cmd.args[1], Valid: true}
+	n := sql.NullString{String: name, Valid: true}
+	u := sql.NullString{String: feedURL, Valid: true}
 
 	// Create the feed and get the created feed back
 	createdFeed, err := s.db.CreateFeed(context.Background(),
 		database.CreateFeedParams{
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-			Name:      n,
-			Url:       u,
-			UserID:    user.ID,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			Name:        n,
+			Url:         u,
+			UserID:      user.ID,
+			SiteLink:    sql.NullString{String: feedContent.Channel.Link, Valid: feedContent.Channel.Link != ""},
+			Description: sql.NullString{String: feedContent.Channel.Description, Valid: feedContent.Channel.Description != ""},
+			Language:    sql.NullString{String: feedContent.Channel.Language, Valid: feedContent.Channel.Language != ""},
 		})
 	if err != nil {
 		return err
 	}
 
+	// Ingest the posts of the validation fetch
+	stats, err := storeFeedContent(s, createdFeed, feedContent, meta)
+	if err != nil {
+		return err
+	}
+	if err := scheduleNextFetch(s, createdFeed, meta, nil); err != nil {
+		return err
+	}
+
 	// Automatically create a feed follow for the current user
 	feedFollow, err := s.db.CreateFeedFollow(context.Background(),
 		database.CreateFeedFollowParams{
@@ -317,16 +598,12 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 
 	fmt.Printf("Feed created: %s\n", createdFeed.Name.String)
 	fmt.Printf("User %s is now following %s\n", feedFollow.UserName, feedFollow.FeedName.String)
+	fmt.Printf("Ingested %d posts\n", stats.NewPosts)
 	return nil
 }
 
 // handlerUnfollow removes a user from following a feed by its URL
 func handlerUnfollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) < 1 {
-		fmt.Println("feed url is required")
-		os.Exit(1)
-	}
-
 	feedURL := sql.NullString{String: cmd.args[0], Valid: true}
 
 	// Get the feed by URL
@@ -353,41 +630,166 @@ func handlerReset(s *state, cmd command) error {
 	if err != nil {
 		return fmt.Errorf("failed to truncate users table: %s", err)
 	}
-	fmt.Printf("truncated the users table: %v\n", cmd)
+	fmt.Println("truncated the users table")
 	return nil
 }
 
-func (c *commands) run(s *state, cmd command) error {
-	handler, ok := c.handlers[cmd.name]
-	if !ok {
-		return fmt.Errorf("command not registered")
+// scrapeFeeds scrapes the feed that was fetched least recently
+func scrapeFeeds(s *state) (fetchStats, error) {
+	feed, err := s.db.GetNextFeedToFetch(context.Background())
+	if err != nil {
+		return fetchStats{}, fmt.Errorf("failed to get next feed: %w", err)
+	}
+
+	if err := s.db.MarkFeedFetched(context.Background(), feed.ID); err != nil {
+		return fetchStats{}, fmt.Errorf("failed to mark feed %d as fetched: %w", feed.ID, err)
+	}
+
+	return scrapeFeed(s, feed)
+}
+
+// scrapeFeedsConcurrently claims a batch of up to concurrency stale feeds and scrapes them in parallel.
+// Claimed feeds are locked and leased in one statement, so concurrent agg processes don't scrape the
+// same feed while it's being fetched
+func scrapeFeedsConcurrently(s *state, concurrency int) error {
+	feeds, err := s.db.ClaimFeedsToFetch(context.Background(), database.ClaimFeedsToFetchParams{
+		LeaseUntil: time.Now().Add(fetchLease),
+		Limit:      int32(concurrency),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to claim feeds: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, feed := range feeds {
+		wg.Go(func() {
+			stats, err := scrapeFeed(s, feed)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			if stats.NewPosts > 0 || stats.UpdatedPosts > 0 {
+				log.Printf("Feed %s: %d new, %d updated posts", feed.Url.String, stats.NewPosts, stats.UpdatedPosts)
+			}
+		})
 	}
+	wg.Wait()
 
-	return handler(s, cmd)
+	return nil
 }
 
-func (c *commands) register(name string, f func(s *state, cmd command) error) {
-	c.handlers[name] = f
+// fetchStats counts the items of a fetched feed
+type fetchStats struct {
+	ItemsSeen    int
+	NewPosts     int
+	UpdatedPosts int
 }
 
-func scrapeFeeds(s *state) error {
-	feed, err := s.db.GetNextFeedToFetch(context.Background())
+// scrapeFeed fetches a feed, stores its items as posts and records the attempt in the fetch history
+func scrapeFeed(s *state, feed database.Feed) (fetchStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), feedFetchTimeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	meta, stats, err := fetchAndStorePosts(ctx, s, feed)
+
+	fetch := database.CreateFeedFetchParams{
+		FeedID:       feed.ID,
+		StartedAt:    startedAt,
+		DurationMs:   int32(time.Since(startedAt).Milliseconds()),
+		HttpStatus:   sql.NullInt32{Int32: int32(meta.StatusCode), Valid: meta.StatusCode != 0},
+		Bytes:        meta.Bytes,
+		ItemsSeen:    int32(stats.ItemsSeen),
+		NewPosts:     int32(stats.NewPosts),
+		UpdatedPosts: int32(stats.UpdatedPosts),
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get next feed: %w", err)
+		fetch.Error = sql.NullString{String: err.Error(), Valid: true}
+	}
+	if rerr := s.db.CreateFeedFetch(context.Background(), fetch); rerr != nil {
+		log.Printf("Failed to record fetch of feed %s: %v", feed.Url.String, rerr)
+	}
+	if serr := scheduleNextFetch(s, feed, meta, err); serr != nil {
+		log.Printf("Failed to schedule next fetch of feed %s: %v", feed.Url.String, serr)
 	}
 
-	if err := s.db.MarkFeedFetched(context.Background(), feed.ID); err != nil {
-		return fmt.Errorf("failed to mark feed %d as fetched: %w", feed.ID, err)
+	return stats, err
+}
+
+// scheduleNextFetch resets the failure counter of a feed after a successful fetch and schedules it from
+// the publisher's hints. After a failed fetch it backs off exponentially and disables the feed once it
+// failed too many times in a row
+func scheduleNextFetch(s *state, feed database.Feed, meta feedResponse, fetchErr error) error {
+	if fetchErr == nil {
+		minInterval, maxInterval, err := s.conf.FetchIntervalBounds()
+		if err != nil {
+			return err
+		}
+
+		return s.db.MarkFeedFetchSucceeded(context.Background(), database.MarkFeedFetchSucceededParams{
+			ID:          feed.ID,
+			NextFetchAt: nextFetchAt(time.Now(), meta.Hints, minInterval, maxInterval),
+		})
+	}
+
+	failures := feed.ConsecutiveFailures + 1
+	var disabledAt sql.NullTime
+	if int(failures) >= s.conf.FeedFailureLimit() {
+		disabledAt = sql.NullTime{Time: time.Now(), Valid: true}
+		log.Printf("Disabled feed %s after %d consecutive failures", feed.Url.String, failures)
 	}
 
-	// Fetch the feed content
-	feedContent, err := fetchFeed(context.Background(), feed.Url.String)
+	return s.db.MarkFeedFetchFailed(context.Background(), database.MarkFeedFetchFailedParams{
+		ID:                  feed.ID,
+		ConsecutiveFailures: failures,
+		NextFetchAt:         time.Now().Add(max(fetchBackoff(failures), meta.Hints.RetryAfter)),
+		DisabledAt:          disabledAt,
+	})
+}
+
+// fetchBackoff returns the delay before retrying a feed that failed failures times in a row
+func fetchBackoff(failures int32) time.Duration {
+	backoff := minFetchBackoff
+	for i := int32(1); i < failures && backoff < maxFetchBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxFetchBackoff)
+}
+
+// fetchAndStorePosts fetches a feed and stores its items as posts
+func fetchAndStorePosts(ctx context.Context, s *state, feed database.Feed) (feedResponse, fetchStats, error) {
+	stats := fetchStats{}
+
+	// Fetch the feed content, unless it didn't change since the last fetch
+	feedContent, meta, err := fetchFeedConditional(ctx, feed.Url.String, feed.Etag.String, feed.LastModified.String)
 	if err != nil {
-		return fmt.Errorf("failed to fetch feed %s: %w", feed.Url.String, err)
+		return meta, stats, fmt.Errorf("failed to fetch feed %s: %w", feed.Url.String, err)
+	}
+	if meta.NotModified() {
+		// the hints of the channel came with the last full response
+		storedScheduleHints(feed, &meta.Hints)
+		return meta, stats, nil
+	}
+
+	stats, err = storeFeedContent(s, feed, feedContent, meta)
+	return meta, stats, err
+}
+
+// storeFeedContent stores the items of a fetched feed as posts and remembers the validators of the response
+func storeFeedContent(s *state, feed database.Feed, feedContent *RSSFeed, meta feedResponse) (fetchStats, error) {
+	stats := fetchStats{}
+
+	// Posts stored before guids were tracked use their URL as guid. Only feeds that still have such
+	// posts need to move them to the guids of their items
+	legacyPosts, err := s.db.FeedHasLegacyPosts(context.Background(), feed.ID)
+	if err != nil {
+		return stats, fmt.Errorf("failed to check for legacy posts of feed %s: %w", feed.Url.String, err)
 	}
 
 	// Iterate over items and save them to database
 	for _, item := range feedContent.Channel.Item {
+		stats.ItemsSeen++
+
 		// Parse the published date
 		var publishedAt sql.NullTime
 		if item.PubDate != "" {
@@ -400,6 +802,8 @@ func scrapeFeeds(s *state) error {
 				"2006-01-02T15:04:05Z07:00",
 				"2006-01-02T15:04:05Z",
 				"2006-01-02 15:04:05",
+				"2006-01-02T15:04Z07:00",
+				"2006-01-02",
 			}
 
 			for _, format := range formats {
@@ -411,60 +815,200 @@ func scrapeFeeds(s *state) error {
 		}
 
 		// Create post params
-		postParams := database.CreatePostParams{
+		postParams := database.UpsertPostParams{
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
 			Title:       sql.NullString{String: html.UnescapeString(item.Title), Valid: item.Title != ""},
-			Url:         item.Link,
+			Url:         sql.NullString{String: item.Link, Valid: item.Link != ""},
 			Description: sql.NullString{String: html.UnescapeString(item.Description), Valid: item.Description != ""},
 			PublishedAt: publishedAt,
 			FeedID:      feed.ID,
+			Author:      sql.NullString{String: html.UnescapeString(item.Author), Valid: item.Author != ""},
+			Guid:        postGUID(item),
+			Content:     sql.NullString{String: item.Content, Valid: item.Content != ""},
+		}
+		enclosures := itemEnclosures(item)
+		postParams.ContentHash = sql.NullString{String: postContentHash(postParams, enclosures), Valid: true}
+
+		// Move a legacy post to the item's guid so it's updated instead of stored a second time
+		if legacyPosts && postParams.Url.Valid {
+			_, err := s.db.AdoptLegacyPostGUID(context.Background(), database.AdoptLegacyPostGUIDParams{
+				Guid:   postParams.Guid,
+				FeedID: feed.ID,
+				Url:    postParams.Url,
+			})
+			if err != nil {
+				log.Printf("Failed to match post %s to its stored copy: %v", item.Link, err)
+			}
 		}
 
-		// Try to create the post
-		_, err := s.db.CreatePost(context.Background(), postParams)
+		// Create the post, or update it when its content changed. Unchanged posts return no row
+		upserted, err := s.db.UpsertPost(context.Background(), postParams)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
 		if err != nil {
-			// If it's a unique constraint violation (URL already exists), ignore it
-			if strings.Contains(err.Error(), "unique constraint") || strings.Contains(err.Error(), "duplicate key") {
-				continue
-			}
-			// For other errors, log them but don't stop the process
-			log.Printf("Failed to create post %s: %v", item.Link, err)
+			// Log errors but don't stop the process
+			log.Printf("Failed to store post %s: %v", item.Link, err)
+			continue
+		}
+		// posts stored before content hashes existed only get their hash, they didn't change
+		if upserted.Inserted {
+			stats.NewPosts++
+		} else if upserted.Changed {
+			stats.UpdatedPosts++
+		}
+
+		if err := storeEnclosures(s, upserted.ID, enclosures); err != nil {
+			log.Printf("Failed to store enclosures of post %s: %v", item.Link, err)
 		}
 	}
 
-	return nil
+	// Remember the validators so the next fetch can be conditional
+	err = s.db.UpdateFeedHTTPCache(context.Background(), database.UpdateFeedHTTPCacheParams{
+		ID:           feed.ID,
+		Etag:         sql.NullString{String: meta.ETag, Valid: meta.ETag != ""},
+		LastModified: sql.NullString{String: meta.LastModified, Valid: meta.LastModified != ""},
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to store cache headers of feed %s: %w", feed.Url.String, err)
+	}
+
+	// Remember the channel's scheduling hints for the responses that don't repeat them
+	err = s.db.UpdateFeedScheduleHints(context.Background(), database.UpdateFeedScheduleHintsParams{
+		ID:        feed.ID,
+		Ttl:       sql.NullString{String: feedContent.Channel.TTL, Valid: feedContent.Channel.TTL != ""},
+		SkipHours: joinedList(feedContent.Channel.SkipHours),
+		SkipDays:  joinedList(feedContent.Channel.SkipDays),
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to store scheduling hints of feed %s: %w", feed.Url.String, err)
+	}
+
+	return stats, nil
 }
 
-// handlerBrowse shows posts for the current user
+// handlerBrowse shows posts for the current user, newest first. Posts can be filtered by feed, read
+// state and time, ordered by publication or ingestion time and paged with --offset
 func handlerBrowse(s *state, cmd command, user database.User) error {
-	limit := int32(2) // default limit
+	params := database.GetPostsForUserParams{
+		UserID:     user.ID,
+		UnreadOnly: cmd.boolFlag("unread"),
+		OrderBy:    cmd.stringFlag("order"),
+		Limit:      2, // default limit
+	}
 
 	if len(cmd.args) > 0 {
 		parsedLimit, err := strconv.Atoi(cmd.args[0])
+		if err != nil || parsedLimit < 1 {
+			return usageErrorf("invalid limit: %s", cmd.args[0])
+		}
+		params.Limit = int32(parsedLimit)
+	}
+
+	offset := cmd.intFlag("offset")
+	if offset < 0 {
+		return usageErrorf("invalid offset: %d", offset)
+	}
+	params.Offset = int32(offset)
+
+	if feed := cmd.stringFlag("feed"); feed != "" {
+		params.Feed = sql.NullString{String: feed, Valid: true}
+	}
+	if since := cmd.stringFlag("since"); since != "" {
+		t, err := parseTimeFilter(since, time.Now())
 		if err != nil {
-			return fmt.Errorf("invalid limit: %w", err)
+			return err
 		}
-		limit = int32(parsedLimit)
+		params.Since = sql.NullTime{Time: t, Valid: true}
+	}
+	if until := cmd.stringFlag("until"); until != "" {
+		t, err := parseTimeFilter(until, time.Now())
+		if err != nil {
+			return err
+		}
+		params.Until = sql.NullTime{Time: t, Valid: true}
+	}
+	if params.OrderBy != "published" && params.OrderBy != "ingested" {
+		return usageErrorf("invalid order: %s (use published or ingested)", params.OrderBy)
 	}
 
-	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
-		UserID: user.ID,
-		Limit:  limit,
-	})
+	posts, err := s.db.GetPostsForUser(context.Background(), params)
 	if err != nil {
 		return fmt.Errorf("couldn't get posts for user: %w", err)
 	}
 
-	if len(posts) == 0 {
-		fmt.Println("No posts found for the current user")
+	if len(posts) == 0 && s.output == outputText {
+		if params.UnreadOnly {
+			fmt.Println("No unread posts found for the current user")
+		} else {
+			fmt.Println("No posts found for the current user")
+		}
 		return nil
 	}
 
+	postIDs := []int32{}
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+	enclosures, err := s.db.GetEnclosuresForPosts(context.Background(), postIDs)
+	if err != nil {
+		return fmt.Errorf("couldn't get enclosures for posts: %w", err)
+	}
+	postEnclosures := map[int32][]database.PostEnclosure{}
+	for _, enclosure := range enclosures {
+		postEnclosures[enclosure.PostID] = append(postEnclosures[enclosure.PostID], enclosure)
+	}
+
+	switch s.output {
+	case outputJSON:
+		items := make([]postJSON, 0, len(posts))
+		for _, post := range posts {
+			attachments := make([]attachmentJSON, 0, len(postEnclosures[post.ID]))
+			for _, enclosure := range postEnclosures[post.ID] {
+				attachment := attachmentJSON{URL: enclosure.Url, MimeType: jsonString(enclosure.MimeType)}
+				if enclosure.Length.Valid {
+					attachment.Length = &enclosure.Length.Int64
+				}
+				if enclosure.Duration.Valid {
+					attachment.DurationSeconds = &enclosure.Duration.Int32
+				}
+				attachments = append(attachments, attachment)
+			}
+			items = append(items, postJSON{
+				ID:          post.ID,
+				Title:       post.Title.String,
+				URL:         jsonString(post.Url),
+				Description: jsonString(post.Description),
+				Author:      jsonString(post.Author),
+				PublishedAt: jsonTime(post.PublishedAt),
+				Feed:        post.FeedName.String,
+				Read:        post.Read,
+				Attachments: attachments,
+			})
+		}
+		return writeJSON(items)
+	case outputTable:
+		rows := [][]string{}
+		for _, post := range posts {
+			read := ""
+			if !post.Read {
+				read = "unread"
+			}
+			rows = append(rows, []string{strconv.Itoa(int(post.ID)), tableTime(post.PublishedAt), post.FeedName.String, post.Title.String, read})
+		}
+		return writeTable([]string{"ID", "PUBLISHED", "FEED", "TITLE", "STATE"}, rows)
+	}
+
 	fmt.Printf("Posts for %s:\n", user.Name)
 	for _, post := range posts {
+		if post.Read {
+			fmt.Printf("ID: %d\n", post.ID)
+		} else {
+			fmt.Printf("ID: %d (unread)\n", post.ID)
+		}
 		fmt.Printf("Title: %s\n", post.Title.String)
-		fmt.Printf("URL: %s\n", post.Url)
+		fmt.Printf("URL: %s\n", post.Url.String)
 		if post.Description.Valid {
 			// Limit description to first 100 characters for readability
 			desc := post.Description.String
@@ -473,10 +1017,16 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 			}
 			fmt.Printf("Description: %s\n", desc)
 		}
+		if post.Author.Valid {
+			fmt.Printf("Author: %s\n", post.Author.String)
+		}
 		if post.PublishedAt.Valid {
 			fmt.Printf("Published: %s\n", post.PublishedAt.Time.Format("2006-01-02 15:04:05"))
 		}
 		fmt.Printf("Feed: %s\n", post.FeedName.String)
+		for _, enclosure := range postEnclosures[post.ID] {
+			fmt.Printf("Attachment: %s\n", formatEnclosure(enclosure))
+		}
 		fmt.Println("---")
 	}
 
@@ -486,58 +1036,234 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 // handlerScrape runs a one-time scrape of all feeds
 func handlerScrape(s *state, cmd command) error {
 	fmt.Println("Starting one-time scrape of all feeds...")
-	err := scrapeFeeds(s)
+	stats, err := scrapeFeeds(s)
 	if err != nil {
 		return fmt.Errorf("failed to scrape feeds: %w", err)
 	}
-	fmt.Println("Scrape completed successfully!")
+	fmt.Printf("Scrape completed successfully! %d new, %d updated posts\n", stats.NewPosts, stats.UpdatedPosts)
 	return nil
 }
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("not enough arguments")
-		os.Exit(1)
+// handlerFeedStatus shows the success rate and the last fetch attempts of every feed
+func handlerFeedStatus(s *state, cmd command) error {
+	limit := int32(5) // default number of attempts per feed
+
+	if len(cmd.args) > 0 {
+		parsedLimit, err := strconv.Atoi(cmd.args[0])
+		if err != nil || parsedLimit < 1 {
+			return usageErrorf("invalid limit: %s", cmd.args[0])
+		}
+		limit = int32(parsedLimit)
+	}
+
+	feeds, err := s.db.GetFeedFetchStats(context.Background())
+	if err != nil {
+		return fmt.Errorf("couldn't get feed fetch stats: %w", err)
+	}
+
+	statuses := make([]feedStatusJSON, 0, len(feeds))
+	for _, feed := range feeds {
+		status := feedStatusJSON{
+			Name:                feed.Name.String,
+			URL:                 feed.Url.String,
+			ConsecutiveFailures: feed.ConsecutiveFailures,
+			NextFetchAt:         feed.NextFetchAt,
+			DisabledAt:          jsonTime(feed.DisabledAt),
+			Attempts:            feed.Attempts,
+			Successes:           feed.Successes,
+			Fetches:             []feedFetchJSON{},
+		}
+
+		fetches, err := s.db.GetRecentFeedFetches(context.Background(), database.GetRecentFeedFetchesParams{
+			FeedID: feed.ID,
+			Limit:  limit,
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't get fetches of feed %s: %w", feed.Url.String, err)
+		}
+		for _, fetch := range fetches {
+			fetchStatus := feedFetchJSON{
+				StartedAt:    fetch.StartedAt,
+				DurationMs:   fetch.DurationMs,
+				Bytes:        fetch.Bytes,
+				ItemsSeen:    fetch.ItemsSeen,
+				NewPosts:     fetch.NewPosts,
+				UpdatedPosts: fetch.UpdatedPosts,
+				Error:        jsonString(fetch.Error),
+			}
+			if fetch.HttpStatus.Valid {
+				fetchStatus.HTTPStatus = &fetch.HttpStatus.Int32
+			}
+			status.Fetches = append(status.Fetches, fetchStatus)
+		}
+
+		statuses = append(statuses, status)
 	}
 
-	c := &commands{
-		handlers: make(map[string]func(*state, command) error),
+	switch s.output {
+	case outputJSON:
+		return writeJSON(statuses)
+	case outputTable:
+		rows := [][]string{}
+		for _, status := range statuses {
+			health := "ok"
+			if status.DisabledAt != nil {
+				health = "disabled"
+			} else if status.ConsecutiveFailures > 0 {
+				health = fmt.Sprintf("failing (%d)", status.ConsecutiveFailures)
+			}
+			successRate := "-"
+			if status.Attempts > 0 {
+				successRate = fmt.Sprintf("%.1f%%", float64(status.Successes)*100/float64(status.Attempts))
+			}
+			lastError := ""
+			if len(status.Fetches) > 0 && status.Fetches[0].Error != nil {
+				lastError = *status.Fetches[0].Error
+			}
+			rows = append(rows, []string{status.Name, status.URL, health, successRate, strconv.FormatInt(status.Attempts, 10), lastError})
+		}
+		return writeTable([]string{"NAME", "URL", "STATE", "SUCCESS", "ATTEMPTS", "LAST ERROR"}, rows)
+	}
+
+	for _, status := range statuses {
+		fmt.Printf("%s (%s)\n", status.Name, status.URL)
+		if status.DisabledAt != nil {
+			fmt.Printf("  Disabled since %s, re-enable with: gator feed-enable %s\n", status.DisabledAt.Format("2006-01-02 15:04:05"), status.URL)
+		} else if status.ConsecutiveFailures > 0 {
+			fmt.Printf("  %d consecutive failures, next attempt at %s\n", status.ConsecutiveFailures, status.NextFetchAt.Format("2006-01-02 15:04:05"))
+		}
+		if status.Attempts == 0 {
+			fmt.Println("  never fetched")
+			continue
+		}
+		fmt.Printf("  Success rate: %.1f%% (%d/%d)\n", float64(status.Successes)*100/float64(status.Attempts), status.Successes, status.Attempts)
+
+		for _, fetch := range status.Fetches {
+			httpStatus := "-"
+			if fetch.HTTPStatus != nil {
+				httpStatus = strconv.Itoa(int(*fetch.HTTPStatus))
+			}
+			fmt.Printf("  %s  status %s  %dms  %d bytes  %d items  %d new  %d updated",
+				fetch.StartedAt.Format("2006-01-02 15:04:05"), httpStatus, fetch.DurationMs, fetch.Bytes, fetch.ItemsSeen, fetch.NewPosts, fetch.UpdatedPosts)
+			if fetch.Error != nil {
+				fmt.Printf("  error: %s", *fetch.Error)
+			}
+			fmt.Println()
+		}
 	}
-	s := &state{}
-	var err error
 
-	s.conf, err = config.ReadConfig()
+	return nil
+}
+
+// handlerFeedEnable reactivates a disabled feed and schedules it for the next fetch
+func handlerFeedEnable(s *state, cmd command) error {
+	enabled, err := s.db.EnableFeed(context.Background(), sql.NullString{String: cmd.args[0], Valid: true})
 	if err != nil {
-		fmt.Printf("failed to read config: %s\n", err)
+		return fmt.Errorf("couldn't enable feed: %w", err)
+	}
+	if enabled == 0 {
+		return fmt.Errorf("unknown feed: %s", cmd.args[0])
 	}
-	c.register("login", handlerLogin)
-	c.register("register", handlerRegister)
-	c.register("reset", handlerReset)
-	c.register("users", handlerUsers)
-	c.register("agg", handlerAgg)
-	c.register("addfeed", middlewareLoggedIn(handlerAddFeed))
-	c.register("feeds", handlerFeeds)
-	c.register("follow", middlewareLoggedIn(handlerFollow))
-	c.register("following", middlewareLoggedIn(handlerFollowing))
-	c.register("unfollow", middlewareLoggedIn(handlerUnfollow))
-	c.register("browse", middlewareLoggedIn(handlerBrowse))
-	c.register("scrape", handlerScrape)
 
-	cmd := command{
-		name: os.Args[1],
-		args: os.Args[2:],
+	fmt.Printf("Feed %s has been enabled\n", cmd.args[0])
+	return nil
+}
+
+// handlerRead renders the full content of a post as plain text
+func handlerRead(s *state, cmd command) error {
+	id, err := strconv.Atoi(cmd.args[0])
+	if err != nil {
+		return usageErrorf("invalid post id: %s", cmd.args[0])
+	}
+
+	post, err := s.db.GetPost(context.Background(), int32(id))
+	if err != nil {
+		return fmt.Errorf("couldn't get post %d: %w", id, err)
+	}
+
+	fmt.Println(renderPost(post))
+
+	return nil
+}
+
+// renderPost renders a post with its details and its full content as plain text
+func renderPost(post database.GetPostRow) string {
+	text := strings.Builder{}
+	text.WriteString(post.Title.String + "\n")
+	fmt.Fprintf(&text, "Feed: %s\n", post.FeedName.String)
+	if post.Author.Valid {
+		fmt.Fprintf(&text, "Author: %s\n", post.Author.String)
+	}
+	if post.PublishedAt.Valid {
+		fmt.Fprintf(&text, "Published: %s\n", post.PublishedAt.Time.Format("2006-01-02 15:04:05"))
+	}
+	if post.Url.Valid {
+		fmt.Fprintf(&text, "URL: %s\n", post.Url.String)
 	}
-	db, err := sql.Open("postgres", s.conf.DBURL)
+	text.WriteString("\n")
+
+	// fall back to the description for feeds without full content
+	content := post.Content.String
+	if !post.Content.Valid {
+		content = post.Description.String
+	}
+	text.WriteString(htmlToText(content))
+
+	return text.String()
+}
+
+func main() {
+	c := newCommands()
+
+	output, args, err := parseOutputOption(os.Args[1:])
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+	if len(args) < 1 {
+		c.printCommands(os.Stderr)
+		os.Exit(exitUsage)
+	}
+
+	// gator --help is gator help
+	if args[0] == "--help" || args[0] == "-h" {
+		args[0] = "help"
+	}
+
+	s := &state{output: output}
+	cmd := command{
+		name: args[0],
+		args: args[1:],
 	}
-	dbQueries := database.New(db)
 
-	s.db = dbQueries
+	// --help and invalid flags are handled by c.run without the config or the database
+	spec, ok := c.specs[cmd.name]
+	if _, _, parseErr := spec.parse(cmd.args); ok && !spec.noConfig && parseErr == nil {
+		s.conf, err = config.ReadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read config: %s\n", err)
+			os.Exit(exitFailure)
+		}
+
+		db, err := sql.Open("postgres", s.conf.DBURL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitFailure)
+		}
+		s.db = database.New(db)
+	}
 
 	if err = c.run(s, cmd); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+
+		var usageErr *usageError
+		if errors.As(err, &usageErr) {
+			if spec, ok := c.specs[cmd.name]; ok {
+				fmt.Fprintln(os.Stderr)
+				spec.printUsage(os.Stderr)
+			}
+			os.Exit(exitUsage)
+		}
+		os.Exit(exitFailure)
 	}
 }
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/deoreal/gator/internal/database"
+)
+
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title"`
+		DateCreated string `xml:"dateCreated,omitempty"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []OPMLOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type OPMLOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// opmlFeed is a feed outline together with the folder it was found in
+type opmlFeed struct {
+	Title    string
+	XMLURL   string
+	HTMLURL  string
+	Category string
+}
+
+// opmlFeeds flattens the outlines of an OPML document. Outlines without a xmlUrl are folders,
+// nested folders are joined with a slash
+func opmlFeeds(outlines []OPMLOutline, folder string) []opmlFeed {
+	feeds := []opmlFeed{}
+	for _, outline := range outlines {
+		title := strings.TrimSpace(outline.Title)
+		if title == "" {
+			title = strings.TrimSpace(outline.Text)
+		}
+
+		if outline.XMLURL != "" {
+			feeds = append(feeds, opmlFeed{
+				Title:    title,
+				XMLURL:   strings.TrimSpace(outline.XMLURL),
+				HTMLURL:  strings.TrimSpace(outline.HTMLURL),
+				Category: folder,
+			})
+		}
+
+		subfolder := folder
+		if outline.XMLURL == "" && title != "" {
+			subfolder = strings.TrimPrefix(folder+"/"+title, "/")
+		}
+		feeds = append(feeds, opmlFeeds(outline.Outlines, subfolder)...)
+	}
+	return feeds
+}
+
+// handlerImportOPML creates the feeds of an OPML file that don't exist yet and follows all of them,
+// using the outline folders as follow categories. Importing the same file twice changes nothing
+func handlerImportOPML(s *state, cmd command, user database.User) error {
+	data, err := os.ReadFile(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("couldn't read %s: %w", cmd.args[0], err)
+	}
+
+	doc := OPML{}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("couldn't parse %s: %w", cmd.args[0], err)
+	}
+
+	created, existing, failed := 0, 0, 0
+	for _, entry := range opmlFeeds(doc.Body.Outlines, "") {
+		isNew, err := importOPMLFeed(s, user, entry)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("Failed: %s (%s): %v\n", entry.Title, entry.XMLURL, err)
+		case isNew:
+			created++
+			fmt.Printf("Created: %s (%s)\n", entry.Title, entry.XMLURL)
+		default:
+			existing++
+		}
+	}
+
+	fmt.Printf("Imported %s: %d created, %d existing, %d failed\n", cmd.args[0], created, existing, failed)
+	return nil
+}
+
+// importOPMLFeed creates the feed of an outline if needed and follows it, reporting whether it was created
+func importOPMLFeed(s *state, user database.User, entry opmlFeed) (bool, error) {
+	feedURL := sql.NullString{String: entry.XMLURL, Valid: true}
+
+	created := false
+	feedID := int32(0)
+	feed, err := s.db.GetFeed(context.Background(), feedURL)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		name := entry.Title
+		if name == "" {
+			name = entry.XMLURL
+		}
+
+		createdFeed, err := s.db.CreateFeed(context.Background(), database.CreateFeedParams{
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Name:      sql.NullString{String: name, Valid: true},
+			Url:       feedURL,
+			UserID:    user.ID,
+			SiteLink:  sql.NullString{String: entry.HTMLURL, Valid: entry.HTMLURL != ""},
+		})
+		if err != nil {
+			return false, err
+		}
+		created = true
+		feedID = createdFeed.ID
+	case err != nil:
+		return false, err
+	default:
+		feedID = feed.ID
+	}
+
+	err = s.db.UpsertFeedFollow(context.Background(), database.UpsertFeedFollowParams{
+		UserID:   user.ID,
+		FeedID:   feedID,
+		Category: sql.NullString{String: entry.Category, Valid: entry.Category != ""},
+	})
+	if err != nil {
+		return created, err
+	}
+
+	return created, nil
+}
+
+// handlerExportOPML writes the feeds the user follows as an OPML 2.0 document to a file, or to stdout
+// when no file is given. Follow categories become outline folders
+func handlerExportOPML(s *state, cmd command, user database.User) error {
+	follows, err := s.db.GetFeedFollowsForExport(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get feeds for user: %w", err)
+	}
+
+	doc := OPML{Version: "2.0"}
+	doc.Head.Title = fmt.Sprintf("gator subscriptions of %s", user.Name)
+	doc.Head.DateCreated = time.Now().Format(time.RFC1123Z)
+
+	for _, follow := range follows {
+		outline := OPMLOutline{
+			Text:    follow.Name.String,
+			Title:   follow.Name.String,
+			Type:    "rss",
+			XMLURL:  follow.Url.String,
+			HTMLURL: follow.SiteLink.String,
+		}
+
+		outlines := &doc.Body.Outlines
+		if follow.Category.Valid {
+			for folder := range strings.SplitSeq(follow.Category.String, "/") {
+				outlines = opmlFolder(outlines, folder)
+			}
+		}
+		*outlines = append(*outlines, outline)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't encode opml: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	data = append(data, '\n')
+
+	if len(cmd.args) == 0 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(cmd.args[0], data, 0o644); err != nil {
+		return fmt.Errorf("couldn't write %s: %w", cmd.args[0], err)
+	}
+	fmt.Printf("Exported %d feeds to %s\n", len(follows), cmd.args[0])
+	return nil
+}
+
+// opmlFolder returns the children of the folder outline named name, adding the folder if needed
+func opmlFolder(outlines *[]OPMLOutline, name string) *[]OPMLOutline {
+	for i := range *outlines {
+		if (*outlines)[i].XMLURL == "" && (*outlines)[i].Text == name {
+			return &(*outlines)[i].Outlines
+		}
+	}
+	*outlines = append(*outlines, OPMLOutline{Text: name, Title: name})
+	return &(*outlines)[len(*outlines)-1].Outlines
+}
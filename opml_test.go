@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestOPMLFeeds(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []opmlFeed
+	}{
+		{
+			name: "flat",
+			body: `<opml version="2.0"><body>
+  <outline text="Blog" type="rss" xmlUrl="https://example.com/feed" htmlUrl="https://example.com/"/>
+  <outline text="Text" title=" Title " xmlUrl=" https://example.org/feed "/>
+</body></opml>`,
+			want: []opmlFeed{
+				{Title: "Blog", XMLURL: "https://example.com/feed", HTMLURL: "https://example.com/"},
+				{Title: "Title", XMLURL: "https://example.org/feed"},
+			},
+		},
+		{
+			name: "nested folders",
+			body: `<opml version="2.0"><body>
+  <outline text="Tech">
+    <outline text="Go" xmlUrl="https://go.dev/blog/feed.atom"/>
+    <outline title="Linux">
+      <outline text="LWN" xmlUrl="https://lwn.net/headlines/rss"/>
+    </outline>
+  </outline>
+  <outline text="News" xmlUrl="https://example.com/news"/>
+</body></opml>`,
+			want: []opmlFeed{
+				{Title: "Go", XMLURL: "https://go.dev/blog/feed.atom", Category: "Tech"},
+				{Title: "LWN", XMLURL: "https://lwn.net/headlines/rss", Category: "Tech/Linux"},
+				{Title: "News", XMLURL: "https://example.com/news"},
+			},
+		},
+		{
+			name: "untitled folder",
+			body: `<opml version="2.0"><body>
+  <outline>
+    <outline text="Blog" xmlUrl="https://example.com/feed"/>
+  </outline>
+</body></opml>`,
+			want: []opmlFeed{
+				{Title: "Blog", XMLURL: "https://example.com/feed"},
+			},
+		},
+		{
+			name: "empty",
+			body: `<opml version="2.0"><body/></opml>`,
+			want: []opmlFeed{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := OPML{}
+			if err := xml.Unmarshal([]byte(tt.body), &doc); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			got := opmlFeeds(doc.Body.Outlines, "")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("opmlFeeds = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// output formats selected with the global --output option
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputTable = "table"
+)
+
+// parseOutputOption removes the global --output option from the command line arguments and returns
+// the selected format, text by default. Arguments after -- are left alone
+func parseOutputOption(args []string) (string, []string, error) {
+	output := outputText
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		value, found := strings.CutPrefix(args[i], "--output=")
+		if !found && args[i] != "--output" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !found {
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("--output requires a value")
+			}
+			i++
+			value = args[i]
+		}
+		if value != outputText && value != outputJSON && value != outputTable {
+			return "", nil, fmt.Errorf("invalid output format: %s (use text, json or table)", value)
+		}
+		output = value
+	}
+	return output, rest, nil
+}
+
+// writeJSON writes v to stdout as indented JSON
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeTable writes rows to stdout as columns aligned under headers
+func writeTable(headers []string, rows [][]string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		// tabs and newlines in values would break the columns
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = strings.Join(strings.Fields(cell), " ")
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return w.Flush()
+}
+
+// tableTime formats an optional time for a table cell
+func tableTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "-"
+	}
+	return t.Time.Format("2006-01-02 15:04")
+}
+
+// jsonString returns the value of a nullable string for JSON output, nil if it's NULL
+func jsonString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// jsonTime returns the value of a nullable time for JSON output, nil if it's NULL
+func jsonTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// userJSON is a registered user, as listed by users
+type userJSON struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+}
+
+// feedJSON is a feed, as listed by feeds
+type feedJSON struct {
+	ID      int32   `json:"id"`
+	Name    string  `json:"name"`
+	URL     string  `json:"url"`
+	SiteURL *string `json:"site_url"`
+	AddedBy string  `json:"added_by"`
+}
+
+// followingJSON is a followed feed with the number of posts the user hasn't read, as listed by following
+type followingJSON struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Unread int64  `json:"unread"`
+}
+
+// attachmentJSON is a media enclosure of a post
+type attachmentJSON struct {
+	URL             string  `json:"url"`
+	MimeType        *string `json:"mime_type"`
+	Length          *int64  `json:"length"`
+	DurationSeconds *int32  `json:"duration_seconds"`
+}
+
+// postJSON is a post of a followed feed, as listed by browse
+type postJSON struct {
+	ID          int32            `json:"id"`
+	Title       string           `json:"title"`
+	URL         *string          `json:"url"`
+	Description *string          `json:"description"`
+	Author      *string          `json:"author"`
+	PublishedAt *time.Time       `json:"published_at"`
+	Feed        string           `json:"feed"`
+	Read        bool             `json:"read"`
+	Attachments []attachmentJSON `json:"attachments"`
+}
+
+// searchResultJSON is a post matching a search, with its rank and the matching passages
+type searchResultJSON struct {
+	ID          int32      `json:"id"`
+	Title       string     `json:"title"`
+	URL         *string    `json:"url"`
+	PublishedAt *time.Time `json:"published_at"`
+	Feed        string     `json:"feed"`
+	Rank        float32    `json:"rank"`
+	Headline    string     `json:"headline"`
+}
+
+// starredJSON is a starred post, as listed by starred
+type starredJSON struct {
+	ID          int32      `json:"id"`
+	Title       string     `json:"title"`
+	URL         *string    `json:"url"`
+	PublishedAt *time.Time `json:"published_at"`
+	Feed        string     `json:"feed"`
+	StarredAt   time.Time  `json:"starred_at"`
+}
+
+// enclosureJSON is a media attachment with its post, as listed by enclosures
+type enclosureJSON struct {
+	ID              int32      `json:"id"`
+	PostID          int32      `json:"post_id"`
+	PostTitle       string     `json:"post_title"`
+	PublishedAt     *time.Time `json:"published_at"`
+	Feed            string     `json:"feed"`
+	URL             string     `json:"url"`
+	MimeType        *string    `json:"mime_type"`
+	Length          *int64     `json:"length"`
+	DurationSeconds *int32     `json:"duration_seconds"`
+}
+
+// feedFetchJSON is a fetch attempt of a feed
+type feedFetchJSON struct {
+	StartedAt    time.Time `json:"started_at"`
+	DurationMs   int32     `json:"duration_ms"`
+	HTTPStatus   *int32    `json:"http_status"`
+	Bytes        int64     `json:"bytes"`
+	ItemsSeen    int32     `json:"items_seen"`
+	NewPosts     int32     `json:"new_posts"`
+	UpdatedPosts int32     `json:"updated_posts"`
+	Error        *string   `json:"error"`
+}
+
+// feedStatusJSON is the fetch health of a feed with its latest attempts, as listed by feed-status
+type feedStatusJSON struct {
+	Name                string          `json:"name"`
+	URL                 string          `json:"url"`
+	ConsecutiveFailures int32           `json:"consecutive_failures"`
+	NextFetchAt         time.Time       `json:"next_fetch_at"`
+	DisabledAt          *time.Time      `json:"disabled_at"`
+	Attempts            int64           `json:"attempts"`
+	Successes           int64           `json:"successes"`
+	Fetches             []feedFetchJSON `json:"fetches"`
+}
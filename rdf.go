@@ -0,0 +1,43 @@
+package main
+
+// RDFFeed is a RSS 1.0 document, where items are siblings of the channel
+type RDFFeed struct {
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		Language    string `xml:"http://purl.org/dc/elements/1.1/ language"`
+	} `xml:"channel"`
+	Items []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+}
+
+// toRSSFeed maps a RSS 1.0 feed onto the RSSFeed shape used by scrapeFeeds
+func (r *RDFFeed) toRSSFeed() *RSSFeed {
+	feed := &RSSFeed{}
+	feed.Channel.Title = r.Channel.Title
+	feed.Channel.Link = r.Channel.Link
+	feed.Channel.Description = r.Channel.Description
+	feed.Channel.Language = r.Channel.Language
+
+	for _, item := range r.Items {
+		feed.Channel.Item = append(feed.Channel.Item, RSSItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			PubDate:     item.Date,
+			Author:      item.Creator,
+			GUID:        item.About,
+		})
+	}
+
+	return feed
+}
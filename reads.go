@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/deoreal/gator/internal/database"
+)
+
+// checkPostSelection validates that a command selects posts with exactly one of a post id, --all and
+// --feed, and returns the post id if one was given
+func checkPostSelection(cmd command) (int32, error) {
+	selections := 0
+	for _, selected := range []bool{len(cmd.args) > 0, cmd.boolFlag("all"), cmd.stringFlag("feed") != ""} {
+		if selected {
+			selections++
+		}
+	}
+	if selections != 1 {
+		return 0, usageErrorf("%s: give either a post id, --all or --feed <url>", cmd.name)
+	}
+
+	if len(cmd.args) == 0 {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(cmd.args[0])
+	if err != nil {
+		return 0, usageErrorf("invalid post id: %s", cmd.args[0])
+	}
+	return int32(id), nil
+}
+
+// handlerMarkRead marks a post, all posts of a feed (--feed <url>) or all posts the user follows
+// (--all) as read
+func handlerMarkRead(s *state, cmd command, user database.User) error {
+	id, err := checkPostSelection(cmd)
+	if err != nil {
+		return err
+	}
+
+	var marked int64
+	switch {
+	case cmd.boolFlag("all"):
+		marked, err = s.db.MarkAllPostsRead(context.Background(), user.ID)
+	case cmd.stringFlag("feed") != "":
+		marked, err = s.db.MarkFeedPostsRead(context.Background(), database.MarkFeedPostsReadParams{
+			UserID: user.ID,
+			Url:    sql.NullString{String: cmd.stringFlag("feed"), Valid: true},
+		})
+	default:
+		marked, err = s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+			UserID: user.ID,
+			PostID: id,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't mark posts as read: %w", err)
+	}
+
+	fmt.Printf("Marked %d posts as read\n", marked)
+	return nil
+}
+
+// handlerMarkUnread marks a post, all posts of a feed (--feed <url>) or all posts (--all) as unread again
+func handlerMarkUnread(s *state, cmd command, user database.User) error {
+	id, err := checkPostSelection(cmd)
+	if err != nil {
+		return err
+	}
+
+	var marked int64
+	switch {
+	case cmd.boolFlag("all"):
+		marked, err = s.db.MarkAllPostsUnread(context.Background(), user.ID)
+	case cmd.stringFlag("feed") != "":
+		marked, err = s.db.MarkFeedPostsUnread(context.Background(), database.MarkFeedPostsUnreadParams{
+			UserID: user.ID,
+			Url:    sql.NullString{String: cmd.stringFlag("feed"), Valid: true},
+		})
+	default:
+		marked, err = s.db.MarkPostUnread(context.Background(), database.MarkPostUnreadParams{
+			UserID: user.ID,
+			PostID: id,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't mark posts as unread: %w", err)
+	}
+
+	fmt.Printf("Marked %d posts as unread\n", marked)
+	return nil
+}
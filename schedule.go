@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deoreal/gator/internal/database"
+)
+
+// scheduleHints are the publisher's hints on when a feed should be fetched again
+type scheduleHints struct {
+	TTL        time.Duration
+	MaxAge     time.Duration
+	RetryAfter time.Duration
+	SkipHours  map[int]bool
+	SkipDays   map[time.Weekday]bool
+}
+
+// channelScheduleHints reads the ttl, skipHours and skipDays elements of a RSS channel
+func channelScheduleHints(feed *RSSFeed, hints *scheduleHints) {
+	if minutes, err := strconv.Atoi(strings.TrimSpace(feed.Channel.TTL)); err == nil && minutes > 0 {
+		hints.TTL = time.Duration(minutes) * time.Minute
+	}
+
+	for _, hour := range feed.Channel.SkipHours {
+		if h, err := strconv.Atoi(strings.TrimSpace(hour)); err == nil && h >= 0 && h < 24 {
+			if hints.SkipHours == nil {
+				hints.SkipHours = map[int]bool{}
+			}
+			hints.SkipHours[h] = true
+		}
+	}
+
+	for _, day := range feed.Channel.SkipDays {
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if strings.EqualFold(strings.TrimSpace(day), weekday.String()) {
+				if hints.SkipDays == nil {
+					hints.SkipDays = map[time.Weekday]bool{}
+				}
+				hints.SkipDays[weekday] = true
+			}
+		}
+	}
+}
+
+// storedScheduleHints reads the ttl, skipHours and skipDays of a RSS channel as stored with its feed, for
+// responses that don't carry the channel
+func storedScheduleHints(feed database.Feed, hints *scheduleHints) {
+	stored := &RSSFeed{}
+	stored.Channel.TTL = feed.Ttl.String
+	if feed.SkipHours.Valid {
+		stored.Channel.SkipHours = strings.Split(feed.SkipHours.String, ",")
+	}
+	if feed.SkipDays.Valid {
+		stored.Channel.SkipDays = strings.Split(feed.SkipDays.String, ",")
+	}
+	channelScheduleHints(stored, hints)
+}
+
+// joinedList stores the values of a repeated channel element, e.g. the hours of skipHours, in one column
+func joinedList(values []string) sql.NullString {
+	trimmed := []string{}
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return sql.NullString{String: strings.Join(trimmed, ","), Valid: len(trimmed) > 0}
+}
+
+// headerScheduleHints reads the Cache-Control max-age and Retry-After headers of a response
+func headerScheduleHints(header http.Header, now time.Time, hints *scheduleHints) {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok || !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+			hints.MaxAge = time.Duration(seconds) * time.Second
+		}
+	}
+
+	// Retry-After is either a number of seconds or a HTTP date
+	retryAfter := strings.TrimSpace(header.Get("Retry-After"))
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		hints.RetryAfter = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(retryAfter); err == nil && date.After(now) {
+		hints.RetryAfter = date.Sub(now)
+	}
+}
+
+// nextFetchAt returns when a feed is due again. The longest hinted interval is bounded by
+// minInterval and maxInterval, then moved out of the hours and days the channel asks to skip
+func nextFetchAt(now time.Time, hints scheduleHints, minInterval, maxInterval time.Duration) time.Time {
+	interval := max(hints.TTL, hints.MaxAge, hints.RetryAfter, minInterval)
+	if maxInterval > 0 {
+		interval = min(interval, maxInterval)
+	}
+
+	return skipHoursAndDays(now.Add(interval), hints)
+}
+
+// skipHoursAndDays moves t forward to the start of the next hour that isn't skipped.
+// skipHours and skipDays are expressed in GMT
+func skipHoursAndDays(t time.Time, hints scheduleHints) time.Time {
+	if len(hints.SkipHours) == 0 && len(hints.SkipDays) == 0 {
+		return t
+	}
+
+	utc := t.UTC()
+	// a week of hours covers every combination of skipped hours and days
+	for range 7 * 24 {
+		if !hints.SkipHours[utc.Hour()] && !hints.SkipDays[utc.Weekday()] {
+			return utc
+		}
+		utc = utc.Truncate(time.Hour).Add(time.Hour)
+	}
+	return t
+}
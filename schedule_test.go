@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextFetchAt(t *testing.T) {
+	// a Monday
+	now := time.Date(2024, time.January, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		hints       scheduleHints
+		minInterval time.Duration
+		maxInterval time.Duration
+		want        time.Time
+	}{
+		{
+			name:        "no hints",
+			minInterval: 15 * time.Minute,
+			want:        now.Add(15 * time.Minute),
+		},
+		{
+			name:        "ttl above the minimum",
+			hints:       scheduleHints{TTL: time.Hour},
+			minInterval: 15 * time.Minute,
+			want:        now.Add(time.Hour),
+		},
+		{
+			name:        "longest hint wins",
+			hints:       scheduleHints{TTL: time.Hour, MaxAge: 2 * time.Hour, RetryAfter: 30 * time.Minute},
+			minInterval: 15 * time.Minute,
+			want:        now.Add(2 * time.Hour),
+		},
+		{
+			name:        "minimum above the hints",
+			hints:       scheduleHints{MaxAge: time.Minute},
+			minInterval: 15 * time.Minute,
+			want:        now.Add(15 * time.Minute),
+		},
+		{
+			name:        "capped by the maximum",
+			hints:       scheduleHints{TTL: 48 * time.Hour},
+			minInterval: 15 * time.Minute,
+			maxInterval: 24 * time.Hour,
+			want:        now.Add(24 * time.Hour),
+		},
+		{
+			name:        "no maximum",
+			hints:       scheduleHints{RetryAfter: 48 * time.Hour},
+			minInterval: 15 * time.Minute,
+			want:        now.Add(48 * time.Hour),
+		},
+		{
+			name:        "skipped hour",
+			hints:       scheduleHints{TTL: time.Hour, SkipHours: map[int]bool{11: true, 12: true}},
+			minInterval: 15 * time.Minute,
+			want:        time.Date(2024, time.January, 1, 13, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextFetchAt(now, tt.hints, tt.minInterval, tt.maxInterval)
+			if !got.Equal(tt.want) {
+				t.Errorf("nextFetchAt = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipHoursAndDays(t *testing.T) {
+	// a Saturday
+	saturday := time.Date(2024, time.January, 6, 22, 15, 0, 0, time.UTC)
+	berlin := time.FixedZone("CET", 60*60)
+
+	tests := []struct {
+		name  string
+		t     time.Time
+		hints scheduleHints
+		want  time.Time
+	}{
+		{
+			name: "nothing skipped",
+			t:    saturday,
+			want: saturday,
+		},
+		{
+			name:  "hour not skipped",
+			t:     saturday,
+			hints: scheduleHints{SkipHours: map[int]bool{3: true}},
+			want:  saturday,
+		},
+		{
+			name:  "skipped hours run into the next day",
+			t:     saturday,
+			hints: scheduleHints{SkipHours: map[int]bool{22: true, 23: true, 0: true}},
+			want:  time.Date(2024, time.January, 7, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "skipped days",
+			t:     saturday,
+			hints: scheduleHints{SkipDays: map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}},
+			want:  time.Date(2024, time.January, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "skipped hours and days",
+			t:    time.Date(2024, time.January, 7, 23, 15, 0, 0, time.UTC),
+			hints: scheduleHints{
+				SkipHours: map[int]bool{0: true, 1: true},
+				SkipDays:  map[time.Weekday]bool{time.Sunday: true},
+			},
+			want: time.Date(2024, time.January, 8, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "hours are in GMT",
+			t:     time.Date(2024, time.January, 6, 12, 30, 0, 0, berlin),
+			hints: scheduleHints{SkipHours: map[int]bool{11: true}},
+			want:  time.Date(2024, time.January, 6, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "everything skipped",
+			t:    saturday,
+			hints: scheduleHints{SkipDays: map[time.Weekday]bool{
+				time.Sunday: true, time.Monday: true, time.Tuesday: true, time.Wednesday: true,
+				time.Thursday: true, time.Friday: true, time.Saturday: true,
+			}},
+			want: saturday,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := skipHoursAndDays(tt.t, tt.hints)
+			if !got.Equal(tt.want) {
+				t.Errorf("skipHoursAndDays = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deoreal/gator/internal/database"
+)
+
+// handlerSearch searches the posts of the feeds the user follows, best matches first. The query uses
+// web search syntax: quoted phrases, "or" and -excluded words
+func handlerSearch(s *state, cmd command, user database.User) error {
+	params := database.SearchPostsForUserParams{
+		Query:  strings.TrimSpace(strings.Join(cmd.args, " ")),
+		UserID: user.ID,
+		Limit:  int32(cmd.intFlag("limit")),
+	}
+	if params.Query == "" {
+		return usageErrorf("search query is required")
+	}
+	if params.Limit < 1 {
+		return usageErrorf("invalid limit: %d", params.Limit)
+	}
+
+	if feed := cmd.stringFlag("feed"); feed != "" {
+		params.FeedUrl = sql.NullString{String: feed, Valid: true}
+	}
+	if since := cmd.stringFlag("since"); since != "" {
+		t, err := parseTimeFilter(since, time.Now())
+		if err != nil {
+			return err
+		}
+		params.Since = sql.NullTime{Time: t, Valid: true}
+	}
+
+	posts, err := s.db.SearchPostsForUser(context.Background(), params)
+	if err != nil {
+		return fmt.Errorf("couldn't search posts: %w", err)
+	}
+
+	switch s.output {
+	case outputJSON:
+		items := make([]searchResultJSON, 0, len(posts))
+		for _, post := range posts {
+			items = append(items, searchResultJSON{
+				ID:          post.ID,
+				Title:       post.Title.String,
+				URL:         jsonString(post.Url),
+				PublishedAt: jsonTime(post.PublishedAt),
+				Feed:        post.FeedName.String,
+				Rank:        post.Rank,
+				Headline:    strings.Join(strings.Fields(htmlToText(post.Headline)), " "),
+			})
+		}
+		return writeJSON(items)
+	case outputTable:
+		rows := [][]string{}
+		for _, post := range posts {
+			rows = append(rows, []string{strconv.Itoa(int(post.ID)), fmt.Sprintf("%.3f", post.Rank), tableTime(post.PublishedAt), post.FeedName.String, post.Title.String})
+		}
+		return writeTable([]string{"ID", "RANK", "PUBLISHED", "FEED", "TITLE"}, rows)
+	}
+
+	if len(posts) == 0 {
+		fmt.Printf("No posts found for %q\n", params.Query)
+		return nil
+	}
+
+	fmt.Printf("Posts matching %q:\n", params.Query)
+	for _, post := range posts {
+		fmt.Printf("ID: %d\n", post.ID)
+		fmt.Printf("Title: %s\n", post.Title.String)
+		fmt.Printf("URL: %s\n", post.Url.String)
+		if post.PublishedAt.Valid {
+			fmt.Printf("Published: %s\n", post.PublishedAt.Time.Format("2006-01-02 15:04:05"))
+		}
+		fmt.Printf("Feed: %s\n", post.FeedName.String)
+		if headline := htmlToText(post.Headline); headline != "" {
+			fmt.Println(headline)
+		}
+		fmt.Println("---")
+	}
+
+	return nil
+}
+
+// parseTimeFilter parses a point in time given as a date (2006-01-02), a RFC 3339 timestamp, or an age
+// relative to now such as 36h or 7d
+func parseTimeFilter(value string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, usageErrorf("invalid time: %s (use a date like 2006-01-02, a timestamp, or an age like 36h or 7d)", value)
+}
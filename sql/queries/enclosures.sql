@@ -0,0 +1,78 @@
+-- name: CreatePostEnclosure :exec
+INSERT INTO post_enclosures (post_id, url, mime_type, length, duration)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (post_id, url) DO UPDATE SET
+    mime_type = EXCLUDED.mime_type,
+    length = EXCLUDED.length,
+    duration = EXCLUDED.duration;
+
+-- name: DeleteStalePostEnclosures :exec
+DELETE FROM post_enclosures
+WHERE post_id = @post_id AND NOT (url = ANY(@urls::text[]));
+
+-- name: GetEnclosuresForPosts :many
+SELECT * FROM post_enclosures
+WHERE post_id = ANY(@post_ids::int[])
+ORDER BY id;
+
+-- name: GetRecentEnclosuresForUser :many
+SELECT
+    e.id,
+    e.post_id,
+    e.url,
+    e.mime_type,
+    e.length,
+    e.duration,
+    p.title AS post_title,
+    p.published_at,
+    f.name AS feed_name
+FROM post_enclosures e
+JOIN posts p ON e.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON f.id = ff.feed_id
+WHERE ff.user_id = $1
+ORDER BY p.published_at DESC NULLS LAST, e.id DESC
+LIMIT $2;
+
+-- name: GetEnclosuresToDownload :many
+SELECT
+    e.id,
+    e.post_id,
+    e.url,
+    e.download_status,
+    e.download_path,
+    p.feed_id,
+    f.name AS feed_name
+FROM post_enclosures e
+JOIN posts p ON e.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON f.id = ff.feed_id
+WHERE ff.user_id = $1
+  AND (e.download_status IS NULL OR e.download_status IN ('partial', 'failed'))
+  AND e.download_attempts < $2
+ORDER BY e.download_attempts, p.published_at DESC NULLS LAST, e.id DESC
+LIMIT $3;
+
+-- name: IncrementEnclosureDownloadAttempts :exec
+UPDATE post_enclosures SET download_attempts = download_attempts + 1 WHERE id = $1;
+
+-- name: UpdateEnclosureDownload :exec
+UPDATE post_enclosures
+SET download_status = $2, download_path = $3, downloaded_bytes = $4, downloaded_at = $5
+WHERE id = $1;
+
+-- name: GetCompletedDownloads :many
+SELECT
+    pe.id,
+    pe.download_path,
+    pe.downloaded_bytes,
+    EXISTS (SELECT 1 FROM user_stars us WHERE us.post_id = pe.post_id) AS starred
+FROM post_enclosures pe
+WHERE pe.download_status = 'complete'
+ORDER BY pe.downloaded_at;
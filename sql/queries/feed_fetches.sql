@@ -0,0 +1,34 @@
+-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches (feed_id, started_at, duration_ms, http_status, bytes, items_seen, new_posts, updated_posts, error)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+);
+
+-- name: GetFeedFetchStats :many
+SELECT
+    f.id,
+    f.name,
+    f.url,
+    f.consecutive_failures,
+    f.next_fetch_at,
+    f.disabled_at,
+    COUNT(ff.id) AS attempts,
+    COUNT(ff.id) FILTER (WHERE ff.error IS NULL) AS successes
+FROM feeds f
+LEFT JOIN feed_fetches ff ON ff.feed_id = f.id
+GROUP BY f.id
+ORDER BY f.name;
+
+-- name: GetRecentFeedFetches :many
+SELECT * FROM feed_fetches
+WHERE feed_id = $1
+ORDER BY started_at DESC
+LIMIT $2;
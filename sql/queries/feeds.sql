@@ -1,11 +1,14 @@
 -- name: CreateFeed :one
-INSERT INTO feeds (created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (created_at, updated_at, name, url, user_id, site_link, description, language)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7,
+    $8
 )
 RETURNING *;
 --
@@ -15,10 +18,12 @@ SELECT
     feeds.id,
     feeds.name,
     feeds.url,
+    feeds.site_link,
     feeds.user_id,
     users.name AS user_name
     FROM feeds
-JOIN users  ON feeds.user_id = users.id;
+JOIN users  ON feeds.user_id = users.id
+ORDER BY feeds.id;
 --
 -- name: MarkFeedFetched :exec
 UPDATE feeds SET last_fetched_at = NOW() WHERE id = $1;
@@ -27,6 +32,7 @@ UPDATE feeds SET updated_at = NOW() WHERE id = $1;
 
 -- name: GetNextFeedToFetch :one
 SELECT * FROM feeds
+WHERE disabled_at IS NULL AND next_fetch_at <= NOW()
 ORDER BY last_fetched_at NULLS FIRST
 LIMIT 1;
 --
@@ -63,12 +69,60 @@ JOIN feeds f ON i.feed_id = f.id;
 
 -- name: GetFeedFollowsForUser :many
 SELECT
-    f.name
+    f.name,
+    f.url,
+    COUNT(p.id) FILTER (WHERE pr.post_id IS NULL) AS unread
 FROM feed_follows ff
 JOIN feeds f ON ff.feed_id = f.id
 JOIN users u ON ff.user_id = u.id
-WHERE u.name = $1;
+LEFT JOIN posts p ON p.feed_id = f.id
+LEFT JOIN post_reads pr ON pr.post_id = p.id AND pr.user_id = u.id
+WHERE u.name = $1
+GROUP BY f.id
+ORDER BY f.name;
 
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows
 WHERE user_id = $1 AND feed_id = $2;
+
+-- name: UpdateFeedHTTPCache :exec
+UPDATE feeds SET etag = $2, last_modified = $3 WHERE id = $1;
+
+-- name: UpdateFeedScheduleHints :exec
+UPDATE feeds SET ttl = $2, skip_hours = $3, skip_days = $4 WHERE id = $1;
+
+-- name: ClaimFeedsToFetch :many
+UPDATE feeds SET last_fetched_at = NOW(), updated_at = NOW(), next_fetch_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE disabled_at IS NULL AND next_fetch_at <= NOW()
+    ORDER BY last_fetched_at NULLS FIRST
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkFeedFetchSucceeded :exec
+UPDATE feeds SET consecutive_failures = 0, next_fetch_at = $2 WHERE id = $1;
+
+-- name: MarkFeedFetchFailed :exec
+UPDATE feeds SET consecutive_failures = $2, next_fetch_at = $3, disabled_at = $4 WHERE id = $1;
+
+-- name: EnableFeed :execrows
+UPDATE feeds SET disabled_at = NULL, consecutive_failures = 0, next_fetch_at = NOW() WHERE url = $1;
+
+-- name: UpsertFeedFollow :exec
+INSERT INTO feed_follows (user_id, feed_id, category)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, feed_id) DO UPDATE SET category = EXCLUDED.category;
+
+-- name: GetFeedFollowsForExport :many
+SELECT
+    f.name,
+    f.url,
+    f.site_link,
+    ff.category
+FROM feed_follows ff
+JOIN feeds f ON ff.feed_id = f.id
+WHERE ff.user_id = $1
+ORDER BY ff.category NULLS FIRST, f.name;
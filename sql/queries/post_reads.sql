@@ -0,0 +1,37 @@
+-- name: MarkPostRead :execrows
+INSERT INTO post_reads (user_id, post_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :execrows
+DELETE FROM post_reads
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id)
+SELECT ff.user_id, p.id
+FROM posts p
+JOIN feed_follows ff ON p.feed_id = ff.feed_id
+WHERE ff.user_id = $1
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkAllPostsUnread :execrows
+DELETE FROM post_reads
+WHERE user_id = $1;
+
+-- name: MarkFeedPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id)
+SELECT ff.user_id, p.id
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON f.id = ff.feed_id
+WHERE ff.user_id = $1 AND f.url = $2
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkFeedPostsUnread :execrows
+DELETE FROM post_reads pr
+USING posts p, feeds f
+WHERE pr.post_id = p.id
+  AND p.feed_id = f.id
+  AND pr.user_id = $1
+  AND f.url = $2;
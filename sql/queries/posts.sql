@@ -1,17 +1,21 @@
--- name: CreatePost :one
-INSERT INTO posts (created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES (
-    $1,
-    $2,
-    $3,
-    $4,
-    $5,
-    $6,
-    $7
-)
-RETURNING *;
+-- name: AdoptLegacyPostGUID :execrows
+UPDATE posts
+SET guid = sqlc.arg(guid)
+WHERE feed_id = sqlc.arg(feed_id)
+  AND url = sqlc.arg(url)
+  AND guid = url
+  AND content_hash IS NULL
+  AND guid <> sqlc.arg(guid)
+  AND NOT EXISTS (
+    SELECT 1 FROM posts other WHERE other.feed_id = sqlc.arg(feed_id) AND other.guid = sqlc.arg(guid)
+  );
 
--- name: GetPostsForUser :many
+-- name: FeedHasLegacyPosts :one
+SELECT EXISTS (
+    SELECT 1 FROM posts WHERE feed_id = $1 AND guid = url AND content_hash IS NULL
+);
+
+-- name: GetPost :one
 SELECT
     p.id,
     p.created_at,
@@ -21,10 +25,90 @@ SELECT
     p.description,
     p.published_at,
     p.feed_id,
+    p.author,
+    p.guid,
+    p.content_hash,
+    p.content,
     f.name AS feed_name
 FROM posts p
 JOIN feeds f ON p.feed_id = f.id
+WHERE p.id = $1;
+
+-- name: GetPostsForUser :many
+SELECT
+    p.id,
+    p.created_at,
+    p.updated_at,
+    p.title,
+    p.url,
+    p.description,
+    p.published_at,
+    p.feed_id,
+    p.author,
+    f.name AS feed_name,
+    (pr.post_id IS NOT NULL)::boolean AS read
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
 JOIN feed_follows ff ON f.id = ff.feed_id
-WHERE ff.user_id = $1
-ORDER BY p.published_at DESC NULLS LAST
-LIMIT $2;
+LEFT JOIN post_reads pr ON pr.post_id = p.id AND pr.user_id = ff.user_id
+WHERE ff.user_id = sqlc.arg(user_id)
+  AND (NOT sqlc.arg(unread_only)::boolean OR pr.post_id IS NULL)
+  AND (sqlc.narg(feed)::text IS NULL OR f.url = sqlc.narg(feed) OR f.name = sqlc.narg(feed))
+  AND (sqlc.narg(since)::timestamptz IS NULL
+    OR (CASE WHEN sqlc.arg(order_by)::text = 'ingested' THEN p.created_at ELSE p.published_at END) >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamptz IS NULL
+    OR (CASE WHEN sqlc.arg(order_by)::text = 'ingested' THEN p.created_at ELSE p.published_at END) < sqlc.narg(until))
+ORDER BY
+    CASE WHEN sqlc.arg(order_by)::text = 'ingested' THEN p.created_at END DESC,
+    p.published_at DESC NULLS LAST,
+    p.id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: SearchPostsForUser :many
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.published_at,
+    f.name AS feed_name,
+    ts_rank(p.search, query)::real AS rank,
+    ts_headline('english', coalesce(p.content, p.description, ''), query,
+        'MaxFragments=2, MaxWords=25, MinWords=10, StartSel=**, StopSel=**')::text AS headline
+FROM posts p
+JOIN feeds f ON p.feed_id = f.id
+JOIN feed_follows ff ON f.id = ff.feed_id,
+    websearch_to_tsquery('english', sqlc.arg(query)) query
+WHERE ff.user_id = sqlc.arg(user_id)
+  AND p.search @@ query
+  AND (sqlc.narg(feed_url)::text IS NULL OR f.url = sqlc.narg(feed_url))
+  AND (sqlc.narg(since)::timestamptz IS NULL OR p.published_at >= sqlc.narg(since))
+ORDER BY rank DESC, p.published_at DESC NULLS LAST
+LIMIT sqlc.arg('limit');
+
+-- name: UpsertPost :one
+INSERT INTO posts (created_at, updated_at, title, url, description, published_at, feed_id, author, guid, content_hash, content)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11
+)
+ON CONFLICT (feed_id, guid) DO UPDATE SET
+    updated_at = CASE WHEN posts.content_hash IS NULL THEN posts.updated_at ELSE EXCLUDED.updated_at END,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    published_at = EXCLUDED.published_at,
+    author = EXCLUDED.author,
+    content = EXCLUDED.content,
+    content_hash = EXCLUDED.content_hash
+WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+RETURNING id, (xmax = 0) AS inserted, (updated_at = $2) AS changed;
@@ -0,0 +1,22 @@
+-- name: StarPost :execrows
+INSERT INTO user_stars (user_id, post_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: UnstarPost :execrows
+DELETE FROM user_stars
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: GetStarredPostsForUser :many
+SELECT
+    p.id,
+    p.title,
+    p.url,
+    p.published_at,
+    f.name AS feed_name,
+    us.starred_at
+FROM user_stars us
+JOIN posts p ON us.post_id = p.id
+JOIN feeds f ON p.feed_id = f.id
+WHERE us.user_id = $1
+ORDER BY us.starred_at DESC;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE feed_fetches(
+id SERIAL PRIMARY KEY,
+feed_id INTEGER NOT NULL,
+started_at timestamptz NOT NULL,
+duration_ms INTEGER NOT NULL,
+http_status INTEGER,
+bytes BIGINT NOT NULL DEFAULT 0,
+items_seen INTEGER NOT NULL DEFAULT 0,
+new_posts INTEGER NOT NULL DEFAULT 0,
+error TEXT,
+FOREIGN KEY(feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+CREATE INDEX feed_fetches_feed_id_started_at_idx ON feed_fetches(feed_id, started_at DESC);
+
+-- +goose Down
+DROP TABLE feed_fetches;
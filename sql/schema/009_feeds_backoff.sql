@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN next_fetch_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE feeds ADD COLUMN disabled_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN disabled_at;
+ALTER TABLE feeds DROP COLUMN next_fetch_at;
+ALTER TABLE feeds DROP COLUMN consecutive_failures;
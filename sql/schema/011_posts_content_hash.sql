@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content_hash TEXT;
+ALTER TABLE feed_fetches ADD COLUMN updated_posts INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feed_fetches DROP COLUMN updated_posts;
+ALTER TABLE posts DROP COLUMN content_hash;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE post_enclosures(
+id SERIAL PRIMARY KEY,
+created_at timestamptz NOT NULL DEFAULT NOW(),
+post_id INTEGER NOT NULL,
+url TEXT NOT NULL,
+mime_type TEXT,
+length BIGINT,
+duration INTEGER,
+FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+UNIQUE (post_id, url)
+);
+
+-- +goose Down
+DROP TABLE post_enclosures;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE post_enclosures ADD COLUMN download_status TEXT;
+ALTER TABLE post_enclosures ADD COLUMN download_path TEXT;
+ALTER TABLE post_enclosures ADD COLUMN downloaded_bytes BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE post_enclosures ADD COLUMN downloaded_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE post_enclosures DROP COLUMN downloaded_at;
+ALTER TABLE post_enclosures DROP COLUMN downloaded_bytes;
+ALTER TABLE post_enclosures DROP COLUMN download_path;
+ALTER TABLE post_enclosures DROP COLUMN download_status;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE post_reads(
+user_id UUID NOT NULL,
+post_id INTEGER NOT NULL,
+read_at timestamptz NOT NULL DEFAULT NOW(),
+FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+PRIMARY KEY (user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE post_reads;
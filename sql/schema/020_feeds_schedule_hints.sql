@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN ttl TEXT;
+ALTER TABLE feeds ADD COLUMN skip_hours TEXT;
+ALTER TABLE feeds ADD COLUMN skip_days TEXT;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN skip_days;
+ALTER TABLE feeds DROP COLUMN skip_hours;
+ALTER TABLE feeds DROP COLUMN ttl;
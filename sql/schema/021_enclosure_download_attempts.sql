@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE post_enclosures ADD COLUMN download_attempts INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE post_enclosures DROP COLUMN download_attempts;
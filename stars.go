@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/deoreal/gator/internal/database"
+)
+
+// handlerStar saves a post for later
+func handlerStar(s *state, cmd command, user database.User) error {
+	id, err := strconv.Atoi(cmd.args[0])
+	if err != nil {
+		return usageErrorf("invalid post id: %s", cmd.args[0])
+	}
+
+	_, err = s.db.StarPost(context.Background(), database.StarPostParams{UserID: user.ID, PostID: int32(id)})
+	if err != nil {
+		return fmt.Errorf("couldn't star post %d: %w", id, err)
+	}
+
+	fmt.Printf("Post %d has been starred\n", id)
+	return nil
+}
+
+// handlerUnstar removes a post from the saved posts
+func handlerUnstar(s *state, cmd command, user database.User) error {
+	id, err := strconv.Atoi(cmd.args[0])
+	if err != nil {
+		return usageErrorf("invalid post id: %s", cmd.args[0])
+	}
+
+	removed, err := s.db.UnstarPost(context.Background(), database.UnstarPostParams{UserID: user.ID, PostID: int32(id)})
+	if err != nil {
+		return fmt.Errorf("couldn't unstar post %d: %w", id, err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("post %d is not starred", id)
+	}
+
+	fmt.Printf("Post %d has been unstarred\n", id)
+	return nil
+}
+
+// handlerStarred lists the posts the user has starred, most recently starred first
+func handlerStarred(s *state, cmd command, user database.User) error {
+	posts, err := s.db.GetStarredPostsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get starred posts for user: %w", err)
+	}
+
+	switch s.output {
+	case outputJSON:
+		items := make([]starredJSON, 0, len(posts))
+		for _, post := range posts {
+			items = append(items, starredJSON{
+				ID:          post.ID,
+				Title:       post.Title.String,
+				URL:         jsonString(post.Url),
+				PublishedAt: jsonTime(post.PublishedAt),
+				Feed:        post.FeedName.String,
+				StarredAt:   post.StarredAt,
+			})
+		}
+		return writeJSON(items)
+	case outputTable:
+		rows := [][]string{}
+		for _, post := range posts {
+			rows = append(rows, []string{
+				strconv.Itoa(int(post.ID)),
+				post.Title.String,
+				post.FeedName.String,
+				post.StarredAt.Format("2006-01-02 15:04"),
+			})
+		}
+		return writeTable([]string{"ID", "TITLE", "FEED", "STARRED"}, rows)
+	}
+
+	if len(posts) == 0 {
+		fmt.Println("No starred posts found for the current user")
+		return nil
+	}
+
+	fmt.Printf("Starred posts of %s:\n", user.Name)
+	for _, post := range posts {
+		fmt.Printf("ID: %d\n", post.ID)
+		fmt.Printf("Title: %s\n", post.Title.String)
+		fmt.Printf("URL: %s\n", post.Url.String)
+		if post.PublishedAt.Valid {
+			fmt.Printf("Published: %s\n", post.PublishedAt.Time.Format("2006-01-02 15:04:05"))
+		}
+		fmt.Printf("Feed: %s\n", post.FeedName.String)
+		fmt.Printf("Starred: %s\n", post.StarredAt.Format("2006-01-02 15:04:05"))
+		fmt.Println("---")
+	}
+
+	return nil
+}
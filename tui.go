@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/deoreal/gator/internal/database"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// tuiPostLimit is the number of posts loaded into the post list
+const tuiPostLimit = 200
+
+const tuiHelp = "Tab switch pane  j/k move  Enter read  m toggle read  s toggle star  o open link  q quit"
+
+// tuiReader is the state of the terminal reader: the feeds and posts shown and the widgets showing them
+type tuiReader struct {
+	s       *state
+	user    database.User
+	feeds   []database.GetFeedFollowsForUserRow
+	posts   []database.GetPostsForUserRow
+	starred map[int32]bool
+
+	app      *tview.Application
+	feedList *tview.List
+	postList *tview.List
+	article  *tview.TextView
+	status   *tview.TextView
+}
+
+// handlerTUI opens a full-screen reader with a feed list, a post list and an article pane
+func handlerTUI(s *state, cmd command, user database.User) error {
+	r := &tuiReader{
+		s:        s,
+		user:     user,
+		starred:  map[int32]bool{},
+		app:      tview.NewApplication(),
+		feedList: tview.NewList().ShowSecondaryText(false),
+		postList: tview.NewList(),
+		article:  tview.NewTextView().SetWordWrap(true),
+		status:   tview.NewTextView(),
+	}
+
+	r.feedList.SetBorder(true).SetTitle(" Feeds ")
+	r.postList.SetBorder(true).SetTitle(" Posts ")
+	r.article.SetBorder(true).SetTitle(" Article ")
+	r.status.SetText(tuiHelp)
+
+	starred, err := s.db.GetStarredPostsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't get starred posts for user: %w", err)
+	}
+	for _, post := range starred {
+		r.starred[post.ID] = true
+	}
+
+	r.feedList.SetChangedFunc(func(index int, _, _ string, _ rune) {
+		r.loadPosts(index)
+	})
+	r.feedList.SetSelectedFunc(func(int, string, string, rune) {
+		r.app.SetFocus(r.postList)
+	})
+	r.postList.SetChangedFunc(func(index int, _, _ string, _ rune) {
+		r.showPost(index)
+	})
+	r.postList.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		r.setRead(index, true)
+		r.app.SetFocus(r.article)
+	})
+
+	if err := r.loadFeeds(); err != nil {
+		return err
+	}
+
+	panes := []tview.Primitive{r.feedList, r.postList, r.article}
+	r.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyTab, tcell.KeyBacktab:
+			step := 1
+			if event.Key() == tcell.KeyBacktab {
+				step = len(panes) - 1
+			}
+			for i, pane := range panes {
+				if pane.HasFocus() {
+					r.app.SetFocus(panes[(i+step)%len(panes)])
+					break
+				}
+			}
+			return nil
+		case tcell.KeyRune:
+		default:
+			return event
+		}
+
+		switch event.Rune() {
+		case 'q':
+			r.app.Stop()
+		case 'j':
+			return tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)
+		case 'k':
+			return tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)
+		case 'm':
+			index := r.postList.GetCurrentItem()
+			if index < len(r.posts) {
+				r.setRead(index, !r.posts[index].Read)
+			}
+		case 's':
+			r.toggleStar(r.postList.GetCurrentItem())
+		case 'o':
+			r.openPost(r.postList.GetCurrentItem())
+		default:
+			return event
+		}
+		return nil
+	})
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(tview.NewFlex().
+			AddItem(r.feedList, 0, 1, true).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(r.postList, 0, 1, false).
+				AddItem(r.article, 0, 2, false), 0, 3, false), 0, 1, true).
+		AddItem(r.status, 1, 0, false)
+
+	return r.app.SetRoot(layout, true).EnableMouse(true).Run()
+}
+
+// loadFeeds fills the feed list. The first entry shows the posts of all feeds
+func (r *tuiReader) loadFeeds() error {
+	feeds, err := r.s.db.GetFeedFollowsForUser(context.Background(), r.user.Name)
+	if err != nil {
+		return fmt.Errorf("couldn't get feeds for user: %w", err)
+	}
+	r.feeds = feeds
+
+	r.feedList.Clear()
+	for i := range len(feeds) + 1 {
+		r.feedList.AddItem(r.feedLabel(i), "", 0, nil)
+	}
+	return nil
+}
+
+// refreshUnreadCounts updates the unread counts of the feed list in place
+func (r *tuiReader) refreshUnreadCounts() {
+	feeds, err := r.s.db.GetFeedFollowsForUser(context.Background(), r.user.Name)
+	if err != nil || len(feeds) != len(r.feeds) {
+		return
+	}
+	r.feeds = feeds
+	for i := range len(feeds) + 1 {
+		r.feedList.SetItemText(i, r.feedLabel(i), "")
+	}
+}
+
+// feedLabel is the feed list entry at index, with the number of unread posts
+func (r *tuiReader) feedLabel(index int) string {
+	if index == 0 {
+		unread := int64(0)
+		for _, feed := range r.feeds {
+			unread += feed.Unread
+		}
+		return fmt.Sprintf("All feeds (%d)", unread)
+	}
+	feed := r.feeds[index-1]
+	return fmt.Sprintf("%s (%d)", tview.Escape(feed.Name.String), feed.Unread)
+}
+
+// loadPosts fills the post list with the posts of the feed at index of the feed list
+func (r *tuiReader) loadPosts(index int) {
+	params := database.GetPostsForUserParams{
+		UserID:  r.user.ID,
+		OrderBy: "published",
+		Limit:   tuiPostLimit,
+	}
+	if index > 0 && index <= len(r.feeds) {
+		params.Feed = r.feeds[index-1].Url
+	}
+
+	posts, err := r.s.db.GetPostsForUser(context.Background(), params)
+	if err != nil {
+		r.setStatus("couldn't get posts: %v", err)
+		return
+	}
+	r.posts = posts
+
+	r.postList.Clear()
+	r.article.Clear()
+	for i := range posts {
+		main, secondary := r.postLabel(i)
+		r.postList.AddItem(main, secondary, 0, nil)
+	}
+}
+
+// postLabel is the post list entry of the post at index, marking unread and starred posts
+func (r *tuiReader) postLabel(index int) (string, string) {
+	post := r.posts[index]
+
+	marks := ""
+	if !post.Read {
+		marks += "●"
+	}
+	if r.starred[post.ID] {
+		marks += "★"
+	}
+	main := tview.Escape(post.Title.String)
+	if marks != "" {
+		main = marks + " " + main
+	}
+
+	details := []string{post.FeedName.String}
+	if post.PublishedAt.Valid {
+		details = append(details, post.PublishedAt.Time.Format("2006-01-02 15:04"))
+	}
+	return main, tview.Escape(strings.Join(details, " · "))
+}
+
+// showPost renders the post at index of the post list in the article pane
+func (r *tuiReader) showPost(index int) {
+	if index >= len(r.posts) {
+		return
+	}
+
+	post, err := r.s.db.GetPost(context.Background(), r.posts[index].ID)
+	if err != nil {
+		r.setStatus("couldn't get post: %v", err)
+		return
+	}
+
+	r.article.SetText(renderPost(post)).ScrollToBeginning()
+}
+
+// setRead marks the post at index of the post list as read or unread
+func (r *tuiReader) setRead(index int, read bool) {
+	if index >= len(r.posts) || r.posts[index].Read == read {
+		return
+	}
+	post := &r.posts[index]
+
+	var err error
+	if read {
+		_, err = r.s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{UserID: r.user.ID, PostID: post.ID})
+	} else {
+		_, err = r.s.db.MarkPostUnread(context.Background(), database.MarkPostUnreadParams{UserID: r.user.ID, PostID: post.ID})
+	}
+	if err != nil {
+		r.setStatus("couldn't update post %d: %v", post.ID, err)
+		return
+	}
+
+	post.Read = read
+	main, secondary := r.postLabel(index)
+	r.postList.SetItemText(index, main, secondary)
+	r.refreshUnreadCounts()
+}
+
+// toggleStar stars or unstars the post at index of the post list
+func (r *tuiReader) toggleStar(index int) {
+	if index >= len(r.posts) {
+		return
+	}
+	id := r.posts[index].ID
+
+	var err error
+	if r.starred[id] {
+		_, err = r.s.db.UnstarPost(context.Background(), database.UnstarPostParams{UserID: r.user.ID, PostID: id})
+	} else {
+		_, err = r.s.db.StarPost(context.Background(), database.StarPostParams{UserID: r.user.ID, PostID: id})
+	}
+	if err != nil {
+		r.setStatus("couldn't update post %d: %v", id, err)
+		return
+	}
+
+	r.starred[id] = !r.starred[id]
+	main, secondary := r.postLabel(index)
+	r.postList.SetItemText(index, main, secondary)
+}
+
+// openPost opens the link of the post at index of the post list in the system browser
+func (r *tuiReader) openPost(index int) {
+	if index >= len(r.posts) || !r.posts[index].Url.Valid {
+		return
+	}
+
+	if err := openBrowser(r.posts[index].Url.String); err != nil {
+		r.setStatus("couldn't open browser: %v", err)
+		return
+	}
+	r.setRead(index, true)
+}
+
+// setStatus shows a message in the status line
+func (r *tuiReader) setStatus(format string, args ...any) {
+	r.status.SetText(fmt.Sprintf(format, args...))
+}
+
+// openBrowser opens a web link with the system's default handler. Links come from feeds, so anything
+// but a http or https URL is refused rather than handed to the system
+func openBrowser(link string) error {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("not a web link: %s", link)
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u.String())
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u.String())
+	default:
+		cmd = exec.Command("xdg-open", u.String())
+	}
+	return cmd.Start()
+}